@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io/ioutil"
+
+	"github.com/stripe/go-confidant-client/confidant"
+)
+
+// command is a subcommand. run returns the value to print.
+type command struct {
+	name    string
+	args    string
+	help    string
+	minArgs int
+	// maxArgs is the maximum number of arguments, or -1 for no maximum.
+	maxArgs int
+	run     func(ctx context.Context, client *confidant.Client, args []string) (interface{}, error)
+}
+
+var commands = []command{
+	{"get-service", "<service>", "Print a service and its credentials", 1, 1, getService},
+	{"list-services", "", "List services", 0, 0, listServices},
+	{"get-credential", "<credential-id>", "Print a credential", 1, 1, getCredential},
+	{"create-service", "<service> [credential...]", "Create a service with credentials", 1, -1, createService},
+	{"assign", "<service> <credential>...", "Assign credentials to a service", 2, -1, assign},
+	{"unassign", "<service> <credential>...", "Remove credentials from a service", 2, -1, unassign},
+	{"enable", "<service>", "Enable a service", 1, 1, enable},
+	{"disable", "<service>", "Disable a service and remove its credentials", 1, 1, disable},
+	{"grants", "[-ensure] <service>", "Print a service's KMS grants, adding them first with -ensure", 1, 2, grants},
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+func getService(ctx context.Context, client *confidant.Client, args []string) (interface{}, error) {
+	return client.GetServiceWithContext(ctx, args[0])
+}
+
+func listServices(ctx context.Context, client *confidant.Client, args []string) (interface{}, error) {
+	return client.GetServicesWithContext(ctx)
+}
+
+func getCredential(ctx context.Context, client *confidant.Client, args []string) (interface{}, error) {
+	return client.GetCredentialWithContext(ctx, args[0])
+}
+
+func createService(ctx context.Context, client *confidant.Client, args []string) (interface{}, error) {
+	return client.CreateServiceWithContext(ctx, args[0], args[1:])
+}
+
+func assign(ctx context.Context, client *confidant.Client, args []string) (interface{}, error) {
+	return client.UpdateServiceCredentialsWithContext(ctx, args[0], args[1:], nil)
+}
+
+func unassign(ctx context.Context, client *confidant.Client, args []string) (interface{}, error) {
+	return client.UpdateServiceCredentialsWithContext(ctx, args[0], nil, args[1:])
+}
+
+func enable(ctx context.Context, client *confidant.Client, args []string) (interface{}, error) {
+	return client.EnableServiceWithContext(ctx, args[0])
+}
+
+func disable(ctx context.Context, client *confidant.Client, args []string) (interface{}, error) {
+	return client.DisableServiceWithContext(ctx, args[0])
+}
+
+func grants(ctx context.Context, client *confidant.Client, args []string) (interface{}, error) {
+	flags := flag.NewFlagSet("grants", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	ensure := flags.Bool("ensure", false, "add missing grants")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return nil, errUsage("grants")
+	}
+	serviceName := flags.Arg(0)
+	if *ensure {
+		if err := client.EnsureGrantsWithContext(ctx, serviceName); err != nil {
+			return nil, err
+		}
+	}
+	return client.GetGrantsWithContext(ctx, serviceName)
+}
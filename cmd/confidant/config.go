@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/stripe/go-confidant-client/confidant"
+)
+
+// config is the configuration of the command. It is read from a profile of the config files
+// and CONFIDANT_* environment variables by confidant.LoadConfig, then overridden by flags.
+type config struct {
+	confidant.Config
+	Output string
+}
+
+// setting is a configuration value that can be set with a flag or environment variable.
+type setting struct {
+	flag  string
+	env   string
+	usage string
+	field func(*config) *string
+}
+
+var settings = []setting{
+	{"url", "CONFIDANT_URL", "URL of the Confidant server", func(c *config) *string { return &c.URL }},
+	{"auth-key", "CONFIDANT_AUTH_KEY", "ID, alias or ARN of the KMS key used to generate auth tokens", func(c *config) *string { return &c.AuthKey }},
+	{"from", "CONFIDANT_FROM", "name to authenticate as", func(c *config) *string { return &c.AuthContext.From }},
+	{"to", "CONFIDANT_TO", "name of the Confidant server's auth context", func(c *config) *string { return &c.AuthContext.To }},
+	{"user-type", "CONFIDANT_USER_TYPE", "user type to authenticate as, user (default) or service", func(c *config) *string { return &c.AuthContext.UserType }},
+	{"region", "CONFIDANT_REGION", "AWS region of the KMS key (default us-east-1)", func(c *config) *string { return &c.Region }},
+	{"unix-proxy", "CONFIDANT_UNIX_PROXY", "path of a unix socket to proxy requests through", func(c *config) *string { return &c.UnixProxy }},
+	{"output", "CONFIDANT_OUTPUT", "output format, table (default) or json", func(c *config) *string { return &c.Output }},
+}
+
+// flagOptions holds the values of the command's flags.
+type flagOptions struct {
+	config  string
+	profile string
+	values  map[string]*string
+}
+
+func (o *flagOptions) register(flags *flag.FlagSet) {
+	flags.StringVar(&o.config, "config", "", "path of a YAML or JSON config file (env CONFIDANT_CONFIG, default ~/.confidant then /etc/confidant/config)")
+	flags.StringVar(&o.profile, "profile", "", "profile of the config file to use (env CONFIDANT_PROFILE, default default)")
+	o.values = make(map[string]*string, len(settings))
+	for _, s := range settings {
+		o.values[s.flag] = flags.String(s.flag, "", fmt.Sprintf("%s (env %s)", s.usage, s.env))
+	}
+}
+
+// loadConfig builds the configuration from the config file and environment variables,
+// then overrides it with the flags that were set.
+func loadConfig(flags *flag.FlagSet, options *flagOptions, getenv func(string) string) (*config, error) {
+	var files []string
+	if options.config != "" {
+		files = []string{options.config}
+	}
+	loaded, err := confidant.LoadConfig(options.profile, files...)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &config{Config: *loaded, Output: getenv("CONFIDANT_OUTPUT")}
+	if cfg.Output == "" {
+		cfg.Output = "table"
+	}
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for _, s := range settings {
+		if set[s.flag] {
+			*s.field(cfg) = *options.values[s.flag]
+		}
+	}
+	return cfg, nil
+}
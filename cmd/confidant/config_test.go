@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stripe/go-confidant-client/confidant"
+)
+
+// writeConfigFile writes a config file and returns its path, and a function that removes it.
+func writeConfigFile(t *testing.T, contents string) (string, func()) {
+	dir, err := ioutil.TempDir("", "confidant")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config")
+	if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func TestLoadConfig(t *testing.T) {
+	path, cleanup := writeConfigFile(t, `
+production:
+  url: https://file.example.com
+  auth_key: alias/file
+  auth_context:
+    from: file-user
+    to: confidant-production
+  region: us-west-2
+`)
+	defer cleanup()
+	os.Setenv("CONFIDANT_AUTH_KEY", "alias/env")
+	os.Setenv("CONFIDANT_FROM", "env-user")
+	defer os.Unsetenv("CONFIDANT_AUTH_KEY")
+	defer os.Unsetenv("CONFIDANT_FROM")
+
+	flags := flag.NewFlagSet("confidant", flag.ContinueOnError)
+	var options flagOptions
+	options.register(flags)
+	args := []string{"-config", path, "-profile", "production", "-from", "flag-user"}
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{"CONFIDANT_OUTPUT": "json"}
+	cfg, err := loadConfig(flags, &options, func(key string) string { return env[key] })
+	if err != nil {
+		t.Fatalf("Could not load config: %s", err)
+	}
+	expected := config{
+		Config: confidant.Config{
+			URL:         "https://file.example.com",
+			AuthKey:     "alias/env",
+			AuthContext: confidant.ConfigAuthContext{From: "flag-user", To: "confidant-production"},
+			Region:      "us-west-2",
+		},
+		Output: "json",
+	}
+	if *cfg != expected {
+		t.Errorf("Expected config %+v, got %+v", expected, *cfg)
+	}
+}
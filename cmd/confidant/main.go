@@ -0,0 +1,127 @@
+// Command confidant fetches and manages secrets in Confidant.
+//
+// Usage:
+//
+//	confidant [flags] <command> [arguments]
+//
+// Run "confidant -help" for the list of flags and commands.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/stripe/go-confidant-client/confidant"
+)
+
+func main() {
+	c := &cli{
+		stdout:    os.Stdout,
+		stderr:    os.Stderr,
+		getenv:    os.Getenv,
+		newClient: newClient,
+	}
+	os.Exit(c.run(context.Background(), os.Args[1:]))
+}
+
+// cli runs commands, writing their output to stdout and errors to stderr.
+type cli struct {
+	stdout    io.Writer
+	stderr    io.Writer
+	getenv    func(string) string
+	newClient func(*config) (*confidant.Client, error)
+}
+
+// run runs the command in args and returns the exit code:
+// 0 on success, 1 if the command failed and 2 if it was used incorrectly.
+func (c *cli) run(ctx context.Context, args []string) int {
+	flags := flag.NewFlagSet("confidant", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	var options flagOptions
+	options.register(flags)
+	flags.Usage = func() { c.usage(flags) }
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if flags.NArg() == 0 {
+		c.usage(flags)
+		return 2
+	}
+	cmd, ok := findCommand(flags.Arg(0))
+	if !ok {
+		fmt.Fprintf(c.stderr, "Unknown command %q\n", flags.Arg(0))
+		c.usage(flags)
+		return 2
+	}
+	cfg, err := loadConfig(flags, &options, c.getenv)
+	if err != nil {
+		fmt.Fprintln(c.stderr, err)
+		return 2
+	}
+	if cfg.Output != "json" && cfg.Output != "table" {
+		fmt.Fprintf(c.stderr, "Unsupported output format %q, use json or table\n", cfg.Output)
+		return 2
+	}
+	cmdArgs := flags.Args()[1:]
+	if len(cmdArgs) < cmd.minArgs || (cmd.maxArgs >= 0 && len(cmdArgs) > cmd.maxArgs) {
+		fmt.Fprintln(c.stderr, errUsage(cmd.name))
+		return 2
+	}
+	client, err := c.newClient(cfg)
+	if err != nil {
+		fmt.Fprintln(c.stderr, err)
+		return 2
+	}
+	result, err := cmd.run(ctx, client, cmdArgs)
+	if err != nil {
+		fmt.Fprintln(c.stderr, err)
+		var usageErr *usageError
+		if errors.As(err, &usageErr) {
+			return 2
+		}
+		return 1
+	}
+	if err := writeResult(c.stdout, cfg.Output, result); err != nil {
+		fmt.Fprintln(c.stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func (c *cli) usage(flags *flag.FlagSet) {
+	fmt.Fprintf(c.stderr, "Usage: confidant [flags] <command> [arguments]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(c.stderr, "  %-16s %s\n", cmd.name, cmd.help)
+		fmt.Fprintf(c.stderr, "  %-16s   confidant %s %s\n", "", cmd.name, cmd.args)
+	}
+	fmt.Fprintf(c.stderr, "\nFlags:\n")
+	flags.PrintDefaults()
+	fmt.Fprintf(c.stderr, "\nFlags can also be set with the environment variables listed above, or in a profile of the config file.\n")
+}
+
+// usageError is returned when a command is given the wrong arguments.
+type usageError struct {
+	command string
+}
+
+func errUsage(name string) error {
+	return &usageError{command: name}
+}
+
+func (e *usageError) Error() string {
+	cmd, _ := findCommand(e.command)
+	return fmt.Sprintf("Usage: confidant %s %s", cmd.name, cmd.args)
+}
+
+// newClient returns a client for the configured Confidant server,
+// which authenticates with KMS in the configured region.
+func newClient(cfg *config) (*confidant.Client, error) {
+	return confidant.NewClientWithConfig(&cfg.Config)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	"github.com/stripe/go-confidant-client/confidant"
+)
+
+type mockKMSClient struct {
+	kmsiface.KMSAPI
+}
+
+func (m *mockKMSClient) Encrypt(input *kms.EncryptInput) (*kms.EncryptOutput, error) {
+	return &kms.EncryptOutput{CiphertextBlob: []byte("token")}, nil
+}
+
+// runCommand runs the command against a server that replies to "METHOD/path" keys with responses,
+// and with 404 to other requests.
+func runCommand(t *testing.T, responses map[string]interface{}, env map[string]string, args ...string) (int, string, string) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, ok := responses[r.Method+r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Not found"})
+			return
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer ts.Close()
+	var stdout, stderr bytes.Buffer
+	c := &cli{
+		stdout: &stdout,
+		stderr: &stderr,
+		getenv: func(key string) string { return env[key] },
+		newClient: func(cfg *config) (*confidant.Client, error) {
+			cfg.URL = ts.URL
+			client, err := newClient(cfg)
+			if err != nil {
+				return nil, err
+			}
+			client.TokenGenerator.KMSClient = &mockKMSClient{}
+			return client, nil
+		},
+	}
+	configFile, cleanup := writeConfigFile(t, "{}")
+	defer cleanup()
+	flags := []string{"-config", configFile, "-url", "http://confidant", "-auth-key", "key", "-from", "me", "-to", "confidant"}
+	code := c.run(context.Background(), append(flags, args...))
+	return code, stdout.String(), stderr.String()
+}
+
+func TestGetServiceTable(t *testing.T) {
+	credential := confidant.Credential{ID: "1", Name: "database", CredentialPairs: map[string]string{"user": "admin", "password": "hunter2"}}
+	responses := map[string]interface{}{
+		"GET/v1/services/foo": confidant.Service{ID: "foo", Enabled: true, Revision: 2, Credentials: []*confidant.Credential{&credential}},
+	}
+	code, stdout, stderr := runCommand(t, responses, nil, "get-service", "foo")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr)
+	}
+	expected := strings.Join([]string{
+		"SERVICE  ENABLED  REVISION  MODIFIED BY  MODIFIED DATE",
+		"foo      true     2                      ",
+		"",
+		"CREDENTIAL  ID  KEY       VALUE",
+		"database    1   password  hunter2",
+		"database    1   user      admin",
+		"",
+	}, "\n")
+	if stdout != expected {
+		t.Errorf("Expected output:\n%s\ngot:\n%s", expected, stdout)
+	}
+}
+
+func TestListServicesJSON(t *testing.T) {
+	services := confidant.Services{Services: []confidant.Service{{ID: "foo", Enabled: true}, {ID: "bar"}}}
+	responses := map[string]interface{}{"GET/v1/services": services}
+	code, stdout, stderr := runCommand(t, responses, nil, "-output", "json", "list-services")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr)
+	}
+	var printed confidant.Services
+	if err := json.Unmarshal([]byte(stdout), &printed); err != nil {
+		t.Fatalf("Could not parse output %q: %s", stdout, err)
+	}
+	if len(printed.Services) != 2 || printed.Services[0].ID != "foo" || printed.Services[1].ID != "bar" {
+		t.Errorf("Expected services foo and bar, got %+v", printed.Services)
+	}
+}
+
+func TestGrants(t *testing.T) {
+	grants := confidant.GrantsResponse{Grants: confidant.Grants{EncryptGrant: true, DecryptGrant: true}}
+	responses := map[string]interface{}{
+		"GET/v1/grants/foo": grants,
+		"PUT/v1/grants/foo": grants,
+	}
+	code, stdout, stderr := runCommand(t, responses, map[string]string{"CONFIDANT_OUTPUT": "json"}, "grants", "-ensure", "foo")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr)
+	}
+	if !strings.Contains(stdout, `"encrypt_grant": true`) {
+		t.Errorf("Expected JSON grants, got %s", stdout)
+	}
+}
+
+func TestUsageErrors(t *testing.T) {
+	tests := [][]string{
+		{},
+		{"unknown-command"},
+		{"get-service"},
+		{"get-service", "foo", "bar"},
+		{"grants", "-unknown", "foo"},
+		{"-output", "yaml", "list-services"},
+	}
+	for _, args := range tests {
+		code, _, stderr := runCommand(t, nil, nil, args...)
+		if code != 2 {
+			t.Errorf("%v: expected exit code 2, got %d", args, code)
+		}
+		if stderr == "" {
+			t.Errorf("%v: expected usage on stderr", args)
+		}
+	}
+}
+
+func TestCommandError(t *testing.T) {
+	code, _, stderr := runCommand(t, map[string]interface{}{}, nil, "get-credential", "missing")
+	if code != 1 {
+		t.Errorf("Expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(stderr, "got status code 404") {
+		t.Errorf("Expected the API error on stderr, got %q", stderr)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/stripe/go-confidant-client/confidant"
+)
+
+// writeResult writes the result of a command as indented JSON, or as tables.
+func writeResult(w io.Writer, format string, result interface{}) error {
+	if format == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	switch result := result.(type) {
+	case *confidant.Service:
+		writeServiceTable(tw, result)
+	case *confidant.Services:
+		writeRow(tw, "SERVICE", "ENABLED", "REVISION", "MODIFIED BY", "MODIFIED DATE")
+		for _, service := range result.Services {
+			writeRow(tw, service.ID, service.Enabled, service.Revision, service.ModifiedBy, service.ModifiedDate)
+		}
+	case *confidant.Credential:
+		writeRow(tw, "CREDENTIAL", "ID", "ENABLED", "REVISION", "MODIFIED BY", "MODIFIED DATE")
+		writeRow(tw, result.Name, result.ID, result.Enabled, result.Revision, result.ModifiedBy, result.ModifiedDate)
+		writeRow(tw)
+		writeRow(tw, "KEY", "VALUE")
+		for _, key := range sortedKeys(result.CredentialPairs) {
+			writeRow(tw, key, result.CredentialPairs[key])
+		}
+	case *confidant.Grants:
+		writeRow(tw, "ENCRYPT GRANT", "DECRYPT GRANT")
+		writeRow(tw, result.EncryptGrant, result.DecryptGrant)
+	default:
+		return fmt.Errorf("Can't print %T as a table", result)
+	}
+	return tw.Flush()
+}
+
+func writeServiceTable(tw io.Writer, service *confidant.Service) {
+	writeRow(tw, "SERVICE", "ENABLED", "REVISION", "MODIFIED BY", "MODIFIED DATE")
+	writeRow(tw, service.ID, service.Enabled, service.Revision, service.ModifiedBy, service.ModifiedDate)
+	writeRow(tw)
+	writeRow(tw, "CREDENTIAL", "ID", "KEY", "VALUE")
+	for _, credential := range service.Credentials {
+		if len(credential.CredentialPairs) == 0 {
+			writeRow(tw, credential.Name, credential.ID, "", "")
+		}
+		for _, key := range sortedKeys(credential.CredentialPairs) {
+			writeRow(tw, credential.Name, credential.ID, key, credential.CredentialPairs[key])
+		}
+	}
+	if len(service.BlindCredentials) != 0 {
+		writeRow(tw)
+		writeRow(tw, "BLIND CREDENTIAL", "ID", "KEYS")
+		for _, credential := range service.BlindCredentials {
+			writeRow(tw, credential.Name, credential.ID, strings.Join(credential.CredentialKeys, ","))
+		}
+	}
+}
+
+func writeRow(w io.Writer, columns ...interface{}) {
+	cells := make([]string, len(columns))
+	for i, column := range columns {
+		cells[i] = fmt.Sprint(column)
+	}
+	fmt.Fprintln(w, strings.Join(cells, "\t"))
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
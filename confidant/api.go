@@ -0,0 +1,76 @@
+package confidant
+
+import "context"
+
+// API is the interface of the Confidant operations of Client, which satisfies it.
+// Code that depends on API rather than *Client can be tested with a mock,
+// such as confidanttest.Mock.
+type API interface {
+	// Services
+	GetServices() (*Services, error)
+	GetServicesWithContext(ctx context.Context) (*Services, error)
+	GetService(serviceName string) (*Service, error)
+	GetServiceWithContext(ctx context.Context, serviceName string) (*Service, error)
+	CreateService(serviceName string, credentialNames []string) (*Service, error)
+	CreateServiceWithContext(ctx context.Context, serviceName string, credentialNames []string) (*Service, error)
+	SetServiceCredentials(serviceName string, credentialNames []string) (*Service, error)
+	SetServiceCredentialsWithContext(ctx context.Context, serviceName string, credentialNames []string) (*Service, error)
+	UpdateServiceCredentials(serviceName string, addCredentialNames []string, removeCredentialNames []string) (*Service, error)
+	UpdateServiceCredentialsWithContext(ctx context.Context, serviceName string, addCredentialNames []string, removeCredentialNames []string) (*Service, error)
+	EnableService(serviceName string) (*Service, error)
+	EnableServiceWithContext(ctx context.Context, serviceName string) (*Service, error)
+	DisableService(serviceName string) (*Service, error)
+	DisableServiceWithContext(ctx context.Context, serviceName string) (*Service, error)
+	ServiceCredentials(serviceName string) (map[string]string, error)
+	ServiceCredentialsWithContext(ctx context.Context, serviceName string) (map[string]string, error)
+
+	// Credentials
+	FindCredentialsByName(names []string) ([]*Credential, error)
+	FindCredentialsByNameWithContext(ctx context.Context, names []string) ([]*Credential, error)
+	AssignCredential(serviceName string, credentialName string) error
+	AssignCredentialWithContext(ctx context.Context, serviceName string, credentialName string) error
+	UnassignCredential(serviceName string, credentialName string) error
+	UnassignCredentialWithContext(ctx context.Context, serviceName string, credentialName string) error
+	GetCredential(id string) (*Credential, error)
+	GetCredentialWithContext(ctx context.Context, id string) (*Credential, error)
+	CreateCredential(body *CredentialRequestBody) (*Credential, error)
+	CreateCredentialWithContext(ctx context.Context, body *CredentialRequestBody) (*Credential, error)
+	UpdateCredential(id string, body *CredentialRequestBody) (*Credential, error)
+	UpdateCredentialWithContext(ctx context.Context, id string, body *CredentialRequestBody) (*Credential, error)
+	ListCredentialRevisions(id string) ([]*Credential, error)
+	ListCredentialRevisionsWithContext(ctx context.Context, id string) ([]*Credential, error)
+	RevertCredential(id string, revision int) (*Credential, error)
+	RevertCredentialWithContext(ctx context.Context, id string, revision int) (*Credential, error)
+
+	// Blind credentials
+	GetBlindCredentials() ([]*BlindCredential, error)
+	GetBlindCredentialsWithContext(ctx context.Context) ([]*BlindCredential, error)
+	FindBlindCredentialsByName(names []string) ([]*BlindCredential, error)
+	FindBlindCredentialsByNameWithContext(ctx context.Context, names []string) ([]*BlindCredential, error)
+	GetBlindCredential(id string) (*BlindCredential, error)
+	GetBlindCredentialWithContext(ctx context.Context, id string) (*BlindCredential, error)
+	CreateBlindCredential(body *BlindCredentialRequestBody) (*BlindCredential, error)
+	CreateBlindCredentialWithContext(ctx context.Context, body *BlindCredentialRequestBody) (*BlindCredential, error)
+	UpdateBlindCredential(id string, body *BlindCredentialRequestBody) (*BlindCredential, error)
+	UpdateBlindCredentialWithContext(ctx context.Context, id string, body *BlindCredentialRequestBody) (*BlindCredential, error)
+	SetServiceBlindCredentials(serviceName string, blindCredentialNames []string) (*Service, error)
+	SetServiceBlindCredentialsWithContext(ctx context.Context, serviceName string, blindCredentialNames []string) (*Service, error)
+	UpdateServiceBlindCredentials(serviceName string, addBlindCredentialNames []string, removeBlindCredentialNames []string) (*Service, error)
+	UpdateServiceBlindCredentialsWithContext(ctx context.Context, serviceName string, addBlindCredentialNames []string, removeBlindCredentialNames []string) (*Service, error)
+	AssignBlindCredential(serviceName string, blindCredentialName string) error
+	AssignBlindCredentialWithContext(ctx context.Context, serviceName string, blindCredentialName string) error
+	UnassignBlindCredential(serviceName string, blindCredentialName string) error
+	UnassignBlindCredentialWithContext(ctx context.Context, serviceName string, blindCredentialName string) error
+
+	// Grants
+	GetGrants(serviceName string) (*Grants, error)
+	GetGrantsWithContext(ctx context.Context, serviceName string) (*Grants, error)
+	EnsureGrants(serviceName string) error
+	EnsureGrantsWithContext(ctx context.Context, serviceName string) error
+
+	// Roles
+	CheckRole(serviceName string) error
+	CheckRoleWithContext(ctx context.Context, serviceName string) error
+}
+
+var _ API = (*Client)(nil)
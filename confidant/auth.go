@@ -0,0 +1,168 @@
+package confidant
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/stripe/go-confidant-client/kmsauth"
+)
+
+const (
+	// DefaultXSRFCookieName is the cookie Confidant sends its XSRF token in.
+	DefaultXSRFCookieName = "XSRF-TOKEN"
+	// DefaultXSRFHeaderName is the header Confidant expects the XSRF token in.
+	DefaultXSRFHeaderName = "X-XSRF-TOKEN"
+)
+
+// Authenticator authenticates requests to Confidant, by adding headers or cookies to them.
+// If it is also a ResponseObserver, it is given every response.
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+}
+
+// ResponseObserver is an Authenticator that updates itself from Confidant's responses,
+// such as to keep a session's XSRF token.
+type ResponseObserver interface {
+	ObserveResponse(resp *http.Response)
+}
+
+var errNoAuthenticator = errors.New("No Authenticator or TokenGenerator is set")
+
+// KMSAuthenticator authenticates requests with kmsauth tokens, in the X-Auth-From
+// and X-Auth-Token headers. This is how Clients without an Authenticator authenticate.
+type KMSAuthenticator struct {
+	TokenGenerator *kmsauth.TokenGenerator
+}
+
+// Authenticate adds a token for the request's context to its headers.
+func (a *KMSAuthenticator) Authenticate(req *http.Request) error {
+	if a.TokenGenerator == nil {
+		return errNoAuthenticator
+	}
+	token, err := a.TokenGenerator.GetTokenWithContext(req.Context())
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Auth-From", a.TokenGenerator.GetUsername())
+	req.Header.Set("X-Auth-Token", token)
+	return nil
+}
+
+// HeaderAuthenticator adds the same headers to every request. Without any headers,
+// it authenticates with nothing, for Confidant servers with authentication disabled.
+type HeaderAuthenticator struct {
+	Header http.Header
+}
+
+// Authenticate adds the headers to the request, replacing any it already has.
+func (a *HeaderAuthenticator) Authenticate(req *http.Request) error {
+	for key, values := range a.Header {
+		req.Header[http.CanonicalHeaderKey(key)] = append([]string{}, values...)
+	}
+	return nil
+}
+
+// SessionAuthenticator authenticates requests with the session cookie of a user
+// logged in to Confidant's web interface, and the session's XSRF token, which
+// Confidant requires for requests that change anything. The XSRF token is taken from
+// the XSRF cookie of Confidant's responses, so a GET request, such as GetServices,
+// must be made before others if XSRFToken is not set. Cookies that responses set are
+// kept like a browser would: they replace the cookie with the same name, if any, and
+// are removed when a response expires them.
+// A SessionAuthenticator must not be copied after it is first used.
+type SessionAuthenticator struct {
+	// Cookies are sent with every request.
+	Cookies []*http.Cookie
+	// XSRFToken is the session's XSRF token, if known.
+	XSRFToken string
+	// XSRFCookieName is the cookie the XSRF token is read from. If empty, DefaultXSRFCookieName.
+	XSRFCookieName string
+	// XSRFHeaderName is the header the XSRF token is sent in. If empty, DefaultXSRFHeaderName.
+	XSRFHeaderName string
+
+	mu        sync.Mutex
+	observed  bool
+	cookies   []*http.Cookie
+	xsrfToken string
+}
+
+// Authenticate adds the session cookies and XSRF token to the request.
+func (a *SessionAuthenticator) Authenticate(req *http.Request) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	cookies, xsrfToken := a.Cookies, a.XSRFToken
+	if a.observed {
+		cookies, xsrfToken = a.cookies, a.xsrfToken
+	}
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	if xsrfToken != "" {
+		req.Header.Set(a.xsrfHeaderName(), xsrfToken)
+	}
+	return nil
+}
+
+// ObserveResponse keeps the XSRF token and cookies that the response sets, and
+// removes the ones it expires.
+func (a *SessionAuthenticator) ObserveResponse(resp *http.Response) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.observed {
+		a.observed = true
+		a.cookies = append([]*http.Cookie{}, a.Cookies...)
+		a.xsrfToken = a.XSRFToken
+	}
+	xsrfCookieName := a.XSRFCookieName
+	if xsrfCookieName == "" {
+		xsrfCookieName = DefaultXSRFCookieName
+	}
+	now := time.Now()
+	for _, cookie := range resp.Cookies() {
+		expired := cookie.MaxAge < 0 || (!cookie.Expires.IsZero() && cookie.Expires.Before(now))
+		if cookie.Name == xsrfCookieName {
+			a.xsrfToken = cookie.Value
+			if expired {
+				a.xsrfToken = ""
+			}
+			continue
+		}
+		a.setCookie(cookie.Name, cookie.Value, expired)
+	}
+}
+
+// setCookie replaces the session cookie called name, adds it if there isn't one,
+// or removes it if it has expired.
+func (a *SessionAuthenticator) setCookie(name, value string, expired bool) {
+	for i, cookie := range a.cookies {
+		if cookie.Name != name {
+			continue
+		}
+		if expired {
+			a.cookies = append(a.cookies[:i], a.cookies[i+1:]...)
+		} else {
+			a.cookies[i] = &http.Cookie{Name: name, Value: value}
+		}
+		return
+	}
+	if !expired {
+		a.cookies = append(a.cookies, &http.Cookie{Name: name, Value: value})
+	}
+}
+
+func (a *SessionAuthenticator) xsrfHeaderName() string {
+	if a.XSRFHeaderName == "" {
+		return DefaultXSRFHeaderName
+	}
+	return a.XSRFHeaderName
+}
+
+// authenticator returns the client's Authenticator, or a KMSAuthenticator for its TokenGenerator.
+func (c *Client) authenticator() Authenticator {
+	if c.Authenticator != nil {
+		return c.Authenticator
+	}
+	return &KMSAuthenticator{TokenGenerator: c.TokenGenerator}
+}
@@ -0,0 +1,126 @@
+package confidant
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHeaderAuthenticator(t *testing.T) {
+	var header http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		json.NewEncoder(w).Encode(Services{})
+	}))
+	defer ts.Close()
+
+	client, err := New(ts.URL, WithAuthenticator(&HeaderAuthenticator{Header: http.Header{"x-auth-from": {"dev"}}}))
+	if err != nil {
+		t.Fatalf("Could not create client: %s", err)
+	}
+	if _, err := client.GetServices(); err != nil {
+		t.Fatalf("Could not get services: %s", err)
+	}
+	if header.Get("X-Auth-From") != "dev" || header.Get("X-Auth-Token") != "" {
+		t.Errorf("Expected only the static headers, got %v", header)
+	}
+}
+
+func TestKMSAuthenticator(t *testing.T) {
+	ts, c := CreateMockClientAndServer(map[string]interface{}{"GET/v1/services": Services{}}, t)
+	defer ts.Close()
+	c.Authenticator = &KMSAuthenticator{TokenGenerator: c.TokenGenerator}
+	c.TokenGenerator = nil
+	if _, err := c.GetServices(); err != nil {
+		t.Errorf("Expected the request to be authenticated with a token, got %v", err)
+	}
+
+	c.Authenticator = nil
+	c.RetryPolicy = nil
+	if _, err := c.GetServices(); !errors.Is(err, errNoAuthenticator) {
+		t.Errorf("Expected an error without an Authenticator or TokenGenerator, got %v", err)
+	}
+}
+
+func TestSessionAuthenticator(t *testing.T) {
+	var requests []*http.Request
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		if r.Method == "GET" {
+			http.SetCookie(w, &http.Cookie{Name: "XSRF-TOKEN", Value: "xsrf"})
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "refreshed"})
+			json.NewEncoder(w).Encode(Credential{ID: "id"})
+			return
+		}
+		if r.Header.Get("X-XSRF-TOKEN") != "xsrf" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid XSRF token."})
+			return
+		}
+		json.NewEncoder(w).Encode(Credential{ID: "id"})
+	}))
+	defer ts.Close()
+
+	authenticator := &SessionAuthenticator{Cookies: []*http.Cookie{{Name: "session", Value: "logged-in"}}}
+	client, err := New(ts.URL, WithAuthenticator(authenticator))
+	if err != nil {
+		t.Fatalf("Could not create client: %s", err)
+	}
+	body := &CredentialRequestBody{Name: "db", CredentialPairs: map[string]string{"key": "value"}}
+	var apiErr *APIError
+	if _, err := client.UpdateCredential("id", body); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected a 401 before the XSRF token is known, got %v", err)
+	}
+	if _, err := client.GetCredential("id"); err != nil {
+		t.Fatalf("Could not get credential: %s", err)
+	}
+	if _, err := client.UpdateCredential("id", body); err != nil {
+		t.Errorf("Expected the XSRF token to be sent, got %v", err)
+	}
+
+	if cookie, err := requests[1].Cookie("session"); err != nil || cookie.Value != "logged-in" {
+		t.Errorf("Expected the session cookie, got %v", cookie)
+	}
+	if cookie, err := requests[2].Cookie("session"); err != nil || cookie.Value != "refreshed" {
+		t.Errorf("Expected the refreshed session cookie, got %v", cookie)
+	}
+	if len(requests[2].Cookies()) != 1 {
+		t.Errorf("Expected only the session cookie, got %v", requests[2].Cookies())
+	}
+}
+
+func TestSessionAuthenticatorCookies(t *testing.T) {
+	authenticator := &SessionAuthenticator{Cookies: []*http.Cookie{
+		{Name: "session", Value: "logged-in"},
+		{Name: "remember", Value: "yes"},
+		{Name: "tracking", Value: "abc"},
+	}}
+	header := http.Header{}
+	header.Add("Set-Cookie", (&http.Cookie{Name: "route", Value: "web-1"}).String())
+	header.Add("Set-Cookie", (&http.Cookie{Name: "remember", MaxAge: -1}).String())
+	header.Add("Set-Cookie", (&http.Cookie{Name: "tracking", Value: "abc", Expires: time.Now().Add(-time.Hour)}).String())
+	header.Add("Set-Cookie", (&http.Cookie{Name: "XSRF-TOKEN", Value: "xsrf"}).String())
+	authenticator.ObserveResponse(&http.Response{Header: header})
+
+	req, _ := http.NewRequest("GET", "http://confidant.example.com/v1/services", nil)
+	authenticator.Authenticate(req)
+	cookies := req.Cookies()
+	if len(cookies) != 2 || cookies[0].Name != "session" || cookies[1].Name != "route" || cookies[1].Value != "web-1" {
+		t.Errorf("Expected the new cookie to be added and the expired ones removed, got %v", cookies)
+	}
+	if req.Header.Get("X-XSRF-TOKEN") != "xsrf" {
+		t.Errorf("Expected the XSRF token, got %v", req.Header)
+	}
+
+	header = http.Header{}
+	header.Add("Set-Cookie", (&http.Cookie{Name: "XSRF-TOKEN", MaxAge: -1}).String())
+	authenticator.ObserveResponse(&http.Response{Header: header})
+	req, _ = http.NewRequest("GET", "http://confidant.example.com/v1/services", nil)
+	authenticator.Authenticate(req)
+	if token := req.Header.Get("X-XSRF-TOKEN"); token != "" {
+		t.Errorf("Expected the expired XSRF token to be removed, got %s", token)
+	}
+}
@@ -0,0 +1,144 @@
+package confidant
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	"github.com/fernet/fernet-go"
+	"github.com/stripe/go-confidant-client/kmsauth"
+	"github.com/stripe/go-confidant-client/kmsauth/kmsv1"
+)
+
+const (
+	// BlindCipherType and BlindCipherVersion are the cipher blind credentials are
+	// encrypted with, which are the only ones Confidant supports.
+	BlindCipherType    = "fernet"
+	BlindCipherVersion = 2
+)
+
+// BlindKey is a KMS key that blind credentials are encrypted with in one region.
+// Data keys are generated with DataKeyGenerator, such as a kmsv1 or kmsv2 adapter,
+// or with an SDK v1 KMSClient if it is nil.
+type BlindKey struct {
+	Region           string
+	KeyID            string
+	KMSClient        kmsiface.KMSAPI
+	DataKeyGenerator kmsauth.DataKeyGenerator
+}
+
+// dataKeyGenerator returns the DataKeyGenerator, or an adapter for the SDK v1 KMSClient.
+func (k BlindKey) dataKeyGenerator() (kmsauth.DataKeyGenerator, error) {
+	if k.DataKeyGenerator != nil {
+		return k.DataKeyGenerator, nil
+	}
+	if k.KMSClient == nil {
+		return nil, fmt.Errorf("Blind key for %s has no DataKeyGenerator or KMSClient", k.Region)
+	}
+	return kmsv1.New(k.KMSClient), nil
+}
+
+// EncryptBlindCredentialPairs encrypts credential pairs for a blind credential, the way Confidant does.
+// For each key, it generates a KMS data key with the encryption context and uses it to
+// Fernet-encrypt the pairs, so that only holders of the data key can read them.
+// It returns a request body with the encrypted pairs, data keys, credential keys and cipher set,
+// to which the caller adds the name and any metadata before creating or updating the blind credential.
+func EncryptBlindCredentialPairs(keys []BlindKey, encryptionContext map[string]*string, pairs map[string]string) (*BlindCredentialRequestBody, error) {
+	return EncryptBlindCredentialPairsWithContext(context.Background(), keys, encryptionContext, pairs)
+}
+
+// EncryptBlindCredentialPairsWithContext is like EncryptBlindCredentialPairs, but takes a context for the KMS calls.
+func EncryptBlindCredentialPairsWithContext(ctx context.Context, keys []BlindKey, encryptionContext map[string]*string, pairs map[string]string) (*BlindCredentialRequestBody, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("At least one blind key is required")
+	}
+	plaintext, err := json.Marshal(pairs)
+	if err != nil {
+		return nil, err
+	}
+	body := &BlindCredentialRequestBody{
+		CredentialPairs: make(map[string]string, len(keys)),
+		CredentialKeys:  make([]string, 0, len(pairs)),
+		DataKey:         make(map[string]string, len(keys)),
+		CipherType:      BlindCipherType,
+		CipherVersion:   BlindCipherVersion,
+	}
+	for name := range pairs {
+		body.CredentialKeys = append(body.CredentialKeys, name)
+	}
+	sort.Strings(body.CredentialKeys)
+	for _, key := range keys {
+		generator, err := key.dataKeyGenerator()
+		if err != nil {
+			return nil, err
+		}
+		dataKey, encryptedDataKey, err := generator.GenerateDataKey(ctx, key.KeyID, 32, aws.StringValueMap(encryptionContext))
+		if err != nil {
+			return nil, fmt.Errorf("Could not generate a data key in %s: %w", key.Region, err)
+		}
+		fernetKey, err := newFernetKey(dataKey)
+		if err != nil {
+			return nil, err
+		}
+		ciphertext, err := fernet.EncryptAndSign(plaintext, fernetKey)
+		if err != nil {
+			return nil, err
+		}
+		body.CredentialPairs[key.Region] = string(ciphertext)
+		body.DataKey[key.Region] = base64.StdEncoding.EncodeToString(encryptedDataKey)
+	}
+	return body, nil
+}
+
+// DecryptBlindCredentialPairs decrypts the credential pairs of a blind credential
+// with the data key for region, which is decrypted with decrypter, such as a kmsv1 or
+// kmsv2 adapter, and the encryption context the blind credential was encrypted with.
+func DecryptBlindCredentialPairs(credential *BlindCredential, region string, decrypter kmsauth.Decrypter, encryptionContext map[string]*string) (map[string]string, error) {
+	return DecryptBlindCredentialPairsWithContext(context.Background(), credential, region, decrypter, encryptionContext)
+}
+
+// DecryptBlindCredentialPairsWithContext is like DecryptBlindCredentialPairs, but takes a context for the KMS call.
+func DecryptBlindCredentialPairsWithContext(ctx context.Context, credential *BlindCredential, region string, decrypter kmsauth.Decrypter, encryptionContext map[string]*string) (map[string]string, error) {
+	if credential.CipherType != BlindCipherType || credential.CipherVersion != BlindCipherVersion {
+		return nil, fmt.Errorf("Unsupported cipher %s version %d", credential.CipherType, credential.CipherVersion)
+	}
+	encryptedPairs, ok := credential.CredentialPairs[region]
+	if !ok {
+		return nil, fmt.Errorf("Blind credential %s has no credential pairs for %s", credential.ID, region)
+	}
+	encryptedDataKey, err := base64.StdEncoding.DecodeString(credential.DataKey[region])
+	if err != nil || len(encryptedDataKey) == 0 {
+		return nil, fmt.Errorf("Blind credential %s has no valid data key for %s", credential.ID, region)
+	}
+	dataKey, _, err := decrypter.Decrypt(ctx, "", encryptedDataKey, aws.StringValueMap(encryptionContext))
+	if err != nil {
+		return nil, fmt.Errorf("Could not decrypt the data key in %s: %w", region, err)
+	}
+	fernetKey, err := newFernetKey(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	plaintext := fernet.VerifyAndDecrypt([]byte(encryptedPairs), 0, []*fernet.Key{fernetKey})
+	if plaintext == nil {
+		return nil, fmt.Errorf("Could not decrypt the credential pairs of blind credential %s", credential.ID)
+	}
+	var pairs map[string]string
+	if err := json.Unmarshal(plaintext, &pairs); err != nil {
+		return nil, err
+	}
+	return pairs, nil
+}
+
+// newFernetKey turns a plaintext KMS data key into a Fernet key.
+func newFernetKey(dataKey []byte) (*fernet.Key, error) {
+	var key fernet.Key
+	if len(dataKey) != len(key) {
+		return nil, fmt.Errorf("Data key must be %d bytes, got %d", len(key), len(dataKey))
+	}
+	copy(key[:], dataKey)
+	return &key, nil
+}
@@ -0,0 +1,262 @@
+package confidant
+
+import "context"
+
+// BlindCredential is a credential that was encrypted by its creator, so that Confidant
+// can store it but not read it. CredentialPairs and DataKey are keyed by AWS region:
+// each value of CredentialPairs is the credential pairs encrypted with CipherType,
+// using the data key that is the matching value of DataKey, encrypted with KMS.
+// CredentialKeys are the names of the credential pairs, which are not encrypted.
+type BlindCredential struct {
+	ID              string            `json:"id"`
+	Name            string            `json:"name"`
+	CredentialPairs map[string]string `json:"credential_pairs"`
+	CredentialKeys  []string          `json:"credential_keys"`
+	DataKey         map[string]string `json:"data_key"`
+	CipherType      string            `json:"cipher_type"`
+	CipherVersion   int               `json:"cipher_version"`
+	Metadata        map[string]string `json:"metadata"`
+	Documentation   string            `json:"documentation"`
+	Enabled         bool              `json:"enabled"`
+	Revision        int               `json:"revision"`
+	ModifiedBy      string            `json:"modified_by"`
+	ModifiedDate    string            `json:"modified_date"`
+}
+
+// BlindCredentialRequestBody is the body of requests to create or update a blind credential.
+// Like CredentialRequestBody, updates replace every field.
+type BlindCredentialRequestBody struct {
+	Name            string            `json:"name"`
+	CredentialPairs map[string]string `json:"credential_pairs"`
+	CredentialKeys  []string          `json:"credential_keys"`
+	DataKey         map[string]string `json:"data_key"`
+	CipherType      string            `json:"cipher_type"`
+	CipherVersion   int               `json:"cipher_version"`
+	Metadata        map[string]string `json:"metadata"`
+	Documentation   string            `json:"documentation"`
+	Enabled         bool              `json:"enabled"`
+}
+
+type BlindCredentialResponse struct {
+	BlindCredentials []BlindCredential `json:"blind_credentials"`
+}
+
+// GetBlindCredentials fetches the list of blind credentials.
+// It makes a GET request to /v1/blind_credentials.
+func (c *Client) GetBlindCredentials() ([]*BlindCredential, error) {
+	return c.GetBlindCredentialsWithContext(context.Background())
+}
+
+// GetBlindCredentialsWithContext is like GetBlindCredentials, but takes a context.
+func (c *Client) GetBlindCredentialsWithContext(ctx context.Context) ([]*BlindCredential, error) {
+	var response BlindCredentialResponse
+	err := c.RequestWithContext(ctx, "GET", "/v1/blind_credentials", nil, &response)
+	if err != nil {
+		return nil, err
+	}
+	credentials := make([]*BlindCredential, len(response.BlindCredentials))
+	for i := range response.BlindCredentials {
+		credentials[i] = &response.BlindCredentials[i]
+	}
+	return credentials, nil
+}
+
+// FindBlindCredentialsByName returns a list of blind credentials for the names provided.
+// If any blind credentials are missing, returns a *CredentialsNotFoundError containing their names instead.
+func (c *Client) FindBlindCredentialsByName(names []string) ([]*BlindCredential, error) {
+	return c.FindBlindCredentialsByNameWithContext(context.Background(), names)
+}
+
+// FindBlindCredentialsByNameWithContext is like FindBlindCredentialsByName, but takes a context.
+func (c *Client) FindBlindCredentialsByNameWithContext(ctx context.Context, names []string) ([]*BlindCredential, error) {
+	all, err := c.GetBlindCredentialsWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	credentialsMap := make(map[string]*BlindCredential)
+	for _, credential := range all {
+		credentialsMap[credential.Name] = credential
+	}
+	credentials := make([]*BlindCredential, 0, len(names))
+	missing := make([]string, 0, len(names))
+	for _, name := range names {
+		credential := credentialsMap[name]
+		if credential == nil {
+			missing = append(missing, name)
+		} else {
+			credentials = append(credentials, credential)
+		}
+	}
+	if len(missing) != 0 {
+		return nil, &CredentialsNotFoundError{Names: missing}
+	}
+	return credentials, nil
+}
+
+// GetBlindCredential fetches a blind credential by ID.
+// It makes a GET request to /v1/blind_credentials/id.
+func (c *Client) GetBlindCredential(id string) (*BlindCredential, error) {
+	return c.GetBlindCredentialWithContext(context.Background(), id)
+}
+
+// GetBlindCredentialWithContext is like GetBlindCredential, but takes a context.
+func (c *Client) GetBlindCredentialWithContext(ctx context.Context, id string) (*BlindCredential, error) {
+	var credential BlindCredential
+	err := c.RequestWithContext(ctx, "GET", "/v1/blind_credentials/"+id, nil, &credential)
+	if err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// CreateBlindCredential creates a new blind credential from pairs that are already encrypted.
+// It makes a POST request to /v1/blind_credentials, and returns the created blind credential.
+func (c *Client) CreateBlindCredential(body *BlindCredentialRequestBody) (*BlindCredential, error) {
+	return c.CreateBlindCredentialWithContext(context.Background(), body)
+}
+
+// CreateBlindCredentialWithContext is like CreateBlindCredential, but takes a context.
+func (c *Client) CreateBlindCredentialWithContext(ctx context.Context, body *BlindCredentialRequestBody) (*BlindCredential, error) {
+	var credential BlindCredential
+	err := c.RequestWithContext(ctx, "POST", "/v1/blind_credentials", body, &credential)
+	if err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// UpdateBlindCredential creates a new revision of an existing blind credential.
+// It makes a PUT request to /v1/blind_credentials/id, and returns the updated blind credential.
+func (c *Client) UpdateBlindCredential(id string, body *BlindCredentialRequestBody) (*BlindCredential, error) {
+	return c.UpdateBlindCredentialWithContext(context.Background(), id, body)
+}
+
+// UpdateBlindCredentialWithContext is like UpdateBlindCredential, but takes a context.
+func (c *Client) UpdateBlindCredentialWithContext(ctx context.Context, id string, body *BlindCredentialRequestBody) (*BlindCredential, error) {
+	var credential BlindCredential
+	err := c.RequestWithContext(ctx, "PUT", "/v1/blind_credentials/"+id, body, &credential)
+	if err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// getBlindCredentialIDs returns the IDs of blind credentials.
+func getBlindCredentialIDs(credentials []*BlindCredential) []string {
+	credentialIDs := make([]string, 0, len(credentials))
+	for _, credential := range credentials {
+		credentialIDs = append(credentialIDs, credential.ID)
+	}
+	return credentialIDs
+}
+
+// createBlindCredentialIDs takes a slice of initial blind credentials
+// and blind credentials to add and remove, and returns the resulting IDs.
+func createBlindCredentialIDs(init []*BlindCredential, add []*BlindCredential, remove []*BlindCredential) []string {
+	credentialIDs := make([]string, 0, len(init)+len(add))
+	seen := make(map[string]bool)
+	removed := make(map[string]bool)
+	for _, credential := range remove {
+		removed[credential.ID] = true
+	}
+	for _, credentials := range [][]*BlindCredential{init, add} {
+		for _, credential := range credentials {
+			if seen[credential.ID] || removed[credential.ID] {
+				continue
+			}
+			seen[credential.ID] = true
+			credentialIDs = append(credentialIDs, credential.ID)
+		}
+	}
+	return credentialIDs
+}
+
+// SetServiceBlindCredentials sets the blind credentials for an existing service,
+// keeping its credentials.
+// It returns a pointer to a Service struct.
+func (c *Client) SetServiceBlindCredentials(serviceName string, blindCredentialNames []string) (*Service, error) {
+	return c.SetServiceBlindCredentialsWithContext(context.Background(), serviceName, blindCredentialNames)
+}
+
+// SetServiceBlindCredentialsWithContext is like SetServiceBlindCredentials, but takes a context.
+func (c *Client) SetServiceBlindCredentialsWithContext(ctx context.Context, serviceName string, blindCredentialNames []string) (*Service, error) {
+	service, err := c.fetchService(ctx, serviceName)
+	if err != nil {
+		return nil, err
+	}
+	credentials, err := c.FindBlindCredentialsByNameWithContext(ctx, blindCredentialNames)
+	if err != nil {
+		return nil, err
+	}
+	return c.putServiceBlindCredentials(ctx, serviceName, service, getBlindCredentialIDs(credentials))
+}
+
+// UpdateServiceBlindCredentials updates an existing service by adding or removing blind credentials.
+// It returns a pointer to a Service struct.
+func (c *Client) UpdateServiceBlindCredentials(serviceName string, addBlindCredentialNames []string, removeBlindCredentialNames []string) (*Service, error) {
+	return c.UpdateServiceBlindCredentialsWithContext(context.Background(), serviceName, addBlindCredentialNames, removeBlindCredentialNames)
+}
+
+// UpdateServiceBlindCredentialsWithContext is like UpdateServiceBlindCredentials, but takes a context.
+func (c *Client) UpdateServiceBlindCredentialsWithContext(ctx context.Context, serviceName string, addBlindCredentialNames []string, removeBlindCredentialNames []string) (*Service, error) {
+	service, err := c.fetchService(ctx, serviceName)
+	if err != nil {
+		return nil, err
+	}
+	addCredentials, err := c.FindBlindCredentialsByNameWithContext(ctx, addBlindCredentialNames)
+	if err != nil {
+		return nil, err
+	}
+	removeCredentials, err := c.FindBlindCredentialsByNameWithContext(ctx, removeBlindCredentialNames)
+	if err != nil {
+		return nil, err
+	}
+	credentialIDs := createBlindCredentialIDs(service.BlindCredentials, addCredentials, removeCredentials)
+	return c.putServiceBlindCredentials(ctx, serviceName, service, credentialIDs)
+}
+
+// putServiceBlindCredentials replaces the blind credentials of a service,
+// keeping its credentials, account and enabled state.
+func (c *Client) putServiceBlindCredentials(ctx context.Context, serviceName string, service *Service, blindCredentialIDs []string) (*Service, error) {
+	err := c.EnsureGrantsWithContext(ctx, serviceName)
+	if err != nil {
+		return nil, err
+	}
+	body := RequestBody{
+		Credentials:      getCredentialIDs(service.Credentials),
+		BlindCredentials: blindCredentialIDs,
+		Account:          service.Account,
+		Enabled:          service.Enabled,
+	}
+	var response Service
+	err = c.RequestWithContext(ctx, "PUT", "/v1/services/"+serviceName, &body, &response)
+	if err != nil {
+		return nil, err
+	} else if response.Error != "" {
+		return nil, bodyError("PUT", "/v1/services/"+serviceName, response.Error)
+	}
+	c.cacheService(serviceName, &response)
+	return &response, nil
+}
+
+// AssignBlindCredential assigns a blind credential to a service
+func (c *Client) AssignBlindCredential(serviceName, blindCredentialName string) error {
+	return c.AssignBlindCredentialWithContext(context.Background(), serviceName, blindCredentialName)
+}
+
+// AssignBlindCredentialWithContext is like AssignBlindCredential, but takes a context.
+func (c *Client) AssignBlindCredentialWithContext(ctx context.Context, serviceName, blindCredentialName string) error {
+	_, err := c.UpdateServiceBlindCredentialsWithContext(ctx, serviceName, []string{blindCredentialName}, nil)
+	return err
+}
+
+// UnassignBlindCredential removes a blind credential from a service
+func (c *Client) UnassignBlindCredential(serviceName, blindCredentialName string) error {
+	return c.UnassignBlindCredentialWithContext(context.Background(), serviceName, blindCredentialName)
+}
+
+// UnassignBlindCredentialWithContext is like UnassignBlindCredential, but takes a context.
+func (c *Client) UnassignBlindCredentialWithContext(ctx context.Context, serviceName, blindCredentialName string) error {
+	_, err := c.UpdateServiceBlindCredentialsWithContext(ctx, serviceName, nil, []string{blindCredentialName})
+	return err
+}
@@ -0,0 +1,211 @@
+package confidant
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// createRecordingMockClientAndServer is like CreateMockClientAndServer,
+// but also records the body of the last request to update a service.
+func createRecordingMockClientAndServer(responses map[string]interface{}, t *testing.T) (*httptest.Server, *Client, func() RequestBody) {
+	encodedToken := base64.StdEncoding.EncodeToString([]byte("token"))
+	username := fmt.Sprintf("2/user/%s", "go-confidant-client")
+	handler := createHandlerFunc(t, username, encodedToken, responses)
+	var mu sync.Mutex
+	var last RequestBody
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PUT" && strings.HasPrefix(r.URL.Path, "/v1/services/") {
+			bodyBytes, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				t.Errorf("Unable read request body: %s", err)
+			}
+			mu.Lock()
+			json.Unmarshal(bodyBytes, &last)
+			mu.Unlock()
+			r.Body = ioutil.NopCloser(bytes.NewReader(bodyBytes))
+		}
+		handler(w, r)
+	}))
+	return ts, createMockClient(ts.URL), func() RequestBody {
+		mu.Lock()
+		defer mu.Unlock()
+		return last
+	}
+}
+
+func TestGetBlindCredentials(t *testing.T) {
+	expected := BlindCredential{
+		ID:              "blind-1",
+		Name:            "blind",
+		CredentialPairs: map[string]string{"us-east-1": "ciphertext"},
+		CredentialKeys:  []string{"api_key"},
+		DataKey:         map[string]string{"us-east-1": "data-key"},
+		CipherType:      "fernet",
+		CipherVersion:   2,
+		Enabled:         true,
+		Revision:        1,
+	}
+	responses := map[string]interface{}{
+		"GET/v1/blind_credentials":         BlindCredentialResponse{BlindCredentials: []BlindCredential{expected}},
+		"GET/v1/blind_credentials/blind-1": expected,
+	}
+	ts, c := CreateMockClientAndServer(responses, t)
+	defer ts.Close()
+	credentials, err := c.GetBlindCredentials()
+	if err != nil {
+		t.Fatalf("Could not get blind credentials: %s", err)
+	}
+	if len(credentials) != 1 || !reflect.DeepEqual(*credentials[0], expected) {
+		t.Errorf("Expected blind credentials [%+v], got %+v", expected, credentials)
+	}
+	credential, err := c.GetBlindCredential("blind-1")
+	if err != nil {
+		t.Fatalf("Could not get blind credential: %s", err)
+	}
+	if !reflect.DeepEqual(*credential, expected) {
+		t.Errorf("Expected blind credential %+v, got %+v", expected, *credential)
+	}
+	_, err = c.FindBlindCredentialsByName([]string{"blind", "missing"})
+	if err == nil || err.Error() != "The following credentials do not exist: [missing]" {
+		t.Errorf("Expected error (The following credentials do not exist: [missing]), got %v", err)
+	}
+}
+
+func TestCreateAndUpdateBlindCredential(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		call   func(*Client, *BlindCredentialRequestBody) (*BlindCredential, error)
+	}{
+		{"POST", "/v1/blind_credentials", (*Client).CreateBlindCredential},
+		{"PUT", "/v1/blind_credentials/blind-1", func(c *Client, body *BlindCredentialRequestBody) (*BlindCredential, error) {
+			return c.UpdateBlindCredential("blind-1", body)
+		}},
+	}
+	for _, test := range tests {
+		var raw map[string]interface{}
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != test.method || r.URL.Path != test.path {
+				t.Errorf("Expected %s %s, got %s %s", test.method, test.path, r.Method, r.URL.Path)
+			}
+			json.NewDecoder(r.Body).Decode(&raw)
+			json.NewEncoder(w).Encode(BlindCredential{ID: "blind-1", Name: "blind", Revision: 1})
+		}))
+		c := createMockClient(ts.URL)
+		credential, err := test.call(c, &BlindCredentialRequestBody{Name: "blind", CipherType: "fernet", CipherVersion: 2})
+		ts.Close()
+		if err != nil {
+			t.Fatalf("%s: could not write blind credential: %s", test.method, err)
+		}
+		if credential.ID != "blind-1" {
+			t.Errorf("%s: expected blind credential blind-1, got %+v", test.method, credential)
+		}
+		for _, key := range []string{"credential_pairs", "data_key", "metadata"} {
+			if _, ok := raw[key].(map[string]interface{}); !ok {
+				t.Errorf("%s: expected %s to be an object, got %v", test.method, key, raw[key])
+			}
+		}
+		if _, ok := raw["credential_keys"].([]interface{}); !ok {
+			t.Errorf("%s: expected credential_keys to be a list, got %v", test.method, raw["credential_keys"])
+		}
+	}
+}
+
+func TestServiceUpdatesKeepBlindCredentials(t *testing.T) {
+	serviceName := "foo"
+	credential := Credential{ID: "1", Name: "name"}
+	blindCredential := BlindCredential{ID: "blind-1", Name: "blind"}
+	initialService := Service{
+		ID:               serviceName,
+		Enabled:          true,
+		Credentials:      []*Credential{&credential},
+		BlindCredentials: []*BlindCredential{&blindCredential},
+	}
+	responses := map[string]interface{}{
+		"GET/v1/services/" + serviceName: initialService,
+		"PUT/v1/services/" + serviceName: initialService,
+		"PUT/v1/grants/" + serviceName:   GrantsResponse{Grants: Grants{EncryptGrant: true, DecryptGrant: true}},
+		"GET/v1/credentials":             CredentialResponse{Credentials: []Credential{credential}},
+	}
+	ts, c, lastBody := createRecordingMockClientAndServer(responses, t)
+	defer ts.Close()
+	updates := map[string]func() (*Service, error){
+		"SetServiceCredentials": func() (*Service, error) {
+			return c.SetServiceCredentials(serviceName, []string{"name"})
+		},
+		"UpdateServiceCredentials": func() (*Service, error) {
+			return c.UpdateServiceCredentials(serviceName, nil, []string{"name"})
+		},
+		"EnableService": func() (*Service, error) {
+			return c.EnableService(serviceName)
+		},
+		"DisableService": func() (*Service, error) {
+			return c.DisableService(serviceName)
+		},
+	}
+	for name, update := range updates {
+		if _, err := update(); err != nil {
+			t.Fatalf("%s: could not update service: %s", name, err)
+		}
+		if body := lastBody(); !reflect.DeepEqual(body.BlindCredentials, []string{"blind-1"}) {
+			t.Errorf("%s: expected blind credentials [blind-1], got %v", name, body.BlindCredentials)
+		}
+	}
+}
+
+func TestUpdateServiceBlindCredentials(t *testing.T) {
+	serviceName := "foo"
+	credential := Credential{ID: "1", Name: "name"}
+	initial := BlindCredential{ID: "blind-1", Name: "initial"}
+	added := BlindCredential{ID: "blind-2", Name: "added"}
+	initialService := Service{
+		ID:               serviceName,
+		Enabled:          true,
+		Credentials:      []*Credential{&credential},
+		BlindCredentials: []*BlindCredential{&initial},
+	}
+	responses := map[string]interface{}{
+		"GET/v1/services/" + serviceName: initialService,
+		"PUT/v1/services/" + serviceName: initialService,
+		"PUT/v1/grants/" + serviceName:   GrantsResponse{Grants: Grants{EncryptGrant: true, DecryptGrant: true}},
+		"GET/v1/blind_credentials":       BlindCredentialResponse{BlindCredentials: []BlindCredential{initial, added}},
+	}
+	ts, c, lastBody := createRecordingMockClientAndServer(responses, t)
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		update   func() error
+		expected []string
+	}{
+		{"assign", func() error { return c.AssignBlindCredential(serviceName, "added") }, []string{"blind-1", "blind-2"}},
+		{"unassign", func() error { return c.UnassignBlindCredential(serviceName, "initial") }, []string{}},
+		{"set", func() error {
+			_, err := c.SetServiceBlindCredentials(serviceName, []string{"added"})
+			return err
+		}, []string{"blind-2"}},
+	}
+	for _, test := range tests {
+		if err := test.update(); err != nil {
+			t.Fatalf("%s: could not update blind credentials: %s", test.name, err)
+		}
+		body := lastBody()
+		sort.Strings(body.BlindCredentials)
+		if !reflect.DeepEqual(body.BlindCredentials, test.expected) {
+			t.Errorf("%s: expected blind credentials %v, got %v", test.name, test.expected, body.BlindCredentials)
+		}
+		if !reflect.DeepEqual(body.Credentials, []string{"1"}) {
+			t.Errorf("%s: expected credentials to be kept, got %v", test.name, body.Credentials)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package confidant
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stripe/go-confidant-client/kmsauth"
+	"github.com/stripe/go-confidant-client/kmsauth/kmstest"
+	"github.com/stripe/go-confidant-client/kmsauth/kmsv1"
+)
+
+// newBlindKey returns a BlindKey for an in-memory KMS in region, with the key alias,
+// and a Decrypter for that KMS.
+func newBlindKey(region, alias string) (BlindKey, kmsauth.Decrypter) {
+	fake := kmstest.New()
+	fake.Region = region
+	fake.AddKey(alias)
+	return BlindKey{Region: region, KeyID: alias, KMSClient: fake}, kmsv1.New(fake)
+}
+
+func TestBlindCredentialPairs(t *testing.T) {
+	east, eastDecrypter := newBlindKey("us-east-1", "alias/blind-east")
+	west, westDecrypter := newBlindKey("us-west-2", "alias/blind-west")
+	// Generate the west data key through an adapter rather than the SDK v1 client.
+	west.DataKeyGenerator, west.KMSClient = kmsv1.New(west.KMSClient), nil
+	keys := []BlindKey{east, west}
+	decrypters := []kmsauth.Decrypter{eastDecrypter, westDecrypter}
+	encryptionContext := map[string]*string{"to": aws.String("service-name")}
+	pairs := map[string]string{"username": "admin", "password": "hunter2"}
+	body, err := EncryptBlindCredentialPairs(keys, encryptionContext, pairs)
+	if err != nil {
+		t.Fatalf("Could not encrypt credential pairs: %s", err)
+	}
+	if body.CipherType != "fernet" || body.CipherVersion != 2 {
+		t.Errorf("Expected cipher fernet version 2, got %s version %d", body.CipherType, body.CipherVersion)
+	}
+	if !reflect.DeepEqual(body.CredentialKeys, []string{"password", "username"}) {
+		t.Errorf("Expected credential keys [password username], got %v", body.CredentialKeys)
+	}
+	if body.CredentialPairs["us-east-1"] == body.CredentialPairs["us-west-2"] {
+		t.Errorf("Expected each region to be encrypted with its own data key")
+	}
+
+	credential := BlindCredential{
+		ID:              "blind-1",
+		CredentialPairs: body.CredentialPairs,
+		DataKey:         body.DataKey,
+		CipherType:      body.CipherType,
+		CipherVersion:   body.CipherVersion,
+	}
+	for i, key := range keys {
+		decrypted, err := DecryptBlindCredentialPairs(&credential, key.Region, decrypters[i], encryptionContext)
+		if err != nil {
+			t.Fatalf("%s: could not decrypt credential pairs: %s", key.Region, err)
+		}
+		if !reflect.DeepEqual(decrypted, pairs) {
+			t.Errorf("%s: expected pairs %v, got %v", key.Region, pairs, decrypted)
+		}
+	}
+
+	wrongContext := map[string]*string{"to": aws.String("another-service")}
+	if _, err := DecryptBlindCredentialPairs(&credential, "us-east-1", eastDecrypter, wrongContext); err == nil {
+		t.Errorf("Expected an error when decrypting with the wrong encryption context")
+	}
+	if _, err := DecryptBlindCredentialPairs(&credential, "eu-west-1", eastDecrypter, encryptionContext); err == nil {
+		t.Errorf("Expected an error when decrypting in a region without credential pairs")
+	}
+	credential.CredentialPairs = map[string]string{"us-east-1": body.CredentialPairs["us-west-2"], "us-west-2": body.CredentialPairs["us-west-2"]}
+	if _, err := DecryptBlindCredentialPairs(&credential, "us-east-1", eastDecrypter, encryptionContext); err == nil {
+		t.Errorf("Expected an error when decrypting with another region's data key")
+	}
+}
+
+func TestBlindKeyWithoutKMS(t *testing.T) {
+	keys := []BlindKey{{Region: "us-east-1", KeyID: "alias/blind"}}
+	if _, err := EncryptBlindCredentialPairs(keys, nil, map[string]string{"password": "hunter2"}); err == nil {
+		t.Errorf("Expected an error for a blind key without a DataKeyGenerator or KMSClient")
+	}
+}
@@ -0,0 +1,165 @@
+package confidant
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultServiceCacheTTL is how long NewClient's clients cache services for.
+const DefaultServiceCacheTTL = 5 * time.Minute
+
+// serviceCache is a cache of services by name that is safe for concurrent use.
+// It stores and returns copies, so callers can't change the cached services.
+type serviceCache struct {
+	mu       sync.RWMutex
+	services map[string]cachedService
+}
+
+type cachedService struct {
+	service *Service
+	added   time.Time
+	expires time.Time
+}
+
+func newServiceCache() *serviceCache {
+	return &serviceCache{services: make(map[string]cachedService)}
+}
+
+// get returns a copy of the named service, if it is cached and hasn't expired.
+func (c *serviceCache) get(name string, now time.Time) (*Service, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entry, ok := c.services[name]
+	if !ok || (!entry.expires.IsZero() && !now.Before(entry.expires)) {
+		return nil, false
+	}
+	return entry.service.copy(), true
+}
+
+// set caches a copy of the service. A zero ttl never expires.
+// If maxEntries is positive and the cache is full, the oldest service is evicted.
+func (c *serviceCache) set(name string, service *Service, now time.Time, ttl time.Duration, maxEntries int) {
+	entry := cachedService{service: service.copy(), added: now}
+	if ttl > 0 {
+		entry.expires = now.Add(ttl)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.services[name]; !ok && maxEntries > 0 {
+		for len(c.services) >= maxEntries {
+			c.evictOldest()
+		}
+	}
+	c.services[name] = entry
+}
+
+func (c *serviceCache) evictOldest() {
+	oldest := ""
+	var oldestAdded time.Time
+	for name, entry := range c.services {
+		if oldest == "" || entry.added.Before(oldestAdded) {
+			oldest, oldestAdded = name, entry.added
+		}
+	}
+	delete(c.services, oldest)
+}
+
+func (c *serviceCache) delete(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.services, name)
+}
+
+func (c *serviceCache) flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.services = make(map[string]cachedService)
+}
+
+// cachedService returns the named service from the client's cache, if it is enabled.
+func (c *Client) cachedService(serviceName string) (*Service, bool) {
+	if c.DisableServiceCache || c.services == nil {
+		return nil, false
+	}
+	return c.services.get(serviceName, time.Now())
+}
+
+// cacheService adds a service to the client's cache, if it is enabled.
+func (c *Client) cacheService(serviceName string, service *Service) {
+	if c.DisableServiceCache || c.services == nil {
+		return
+	}
+	c.services.set(serviceName, service, time.Now(), c.ServiceCacheTTL, c.ServiceCacheSize)
+}
+
+// InvalidateService removes a service from the client's cache,
+// so that the next GetService fetches it from Confidant.
+func (c *Client) InvalidateService(serviceName string) {
+	if c.services != nil {
+		c.services.delete(serviceName)
+	}
+}
+
+// FlushServiceCache removes all services from the client's cache.
+func (c *Client) FlushServiceCache() {
+	if c.services != nil {
+		c.services.flush()
+	}
+}
+
+// copy returns a deep copy of the service.
+func (s *Service) copy() *Service {
+	service := *s
+	service.Credentials = copyCredentials(s.Credentials)
+	service.BlindCredentials = copyBlindCredentials(s.BlindCredentials)
+	return &service
+}
+
+func copyCredentials(credentials []*Credential) []*Credential {
+	if credentials == nil {
+		return nil
+	}
+	copied := make([]*Credential, len(credentials))
+	for i, credential := range credentials {
+		if credential == nil {
+			continue
+		}
+		c := *credential
+		c.CredentialPairs = copyStringMap(credential.CredentialPairs)
+		c.Metadata = copyStringMap(credential.Metadata)
+		copied[i] = &c
+	}
+	return copied
+}
+
+func copyBlindCredentials(credentials []*BlindCredential) []*BlindCredential {
+	if credentials == nil {
+		return nil
+	}
+	copied := make([]*BlindCredential, len(credentials))
+	for i, credential := range credentials {
+		if credential == nil {
+			continue
+		}
+		c := *credential
+		c.CredentialPairs = copyStringMap(credential.CredentialPairs)
+		c.DataKey = copyStringMap(credential.DataKey)
+		c.Metadata = copyStringMap(credential.Metadata)
+		if credential.CredentialKeys != nil {
+			c.CredentialKeys = append([]string(nil), credential.CredentialKeys...)
+		}
+		copied[i] = &c
+	}
+	return copied
+}
+
+func copyStringMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	copied := make(map[string]string, len(m))
+	for k, v := range m {
+		copied[k] = v
+	}
+	return copied
+}
@@ -0,0 +1,128 @@
+package confidant
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestServiceCacheTTL(t *testing.T) {
+	cache := newServiceCache()
+	now := time.Date(2018, 7, 25, 1, 26, 17, 0, time.UTC)
+	cache.set("foo", &Service{ID: "foo"}, now, time.Minute, 0)
+	if _, ok := cache.get("foo", now.Add(59*time.Second)); !ok {
+		t.Errorf("Expected foo to be cached")
+	}
+	if _, ok := cache.get("foo", now.Add(time.Minute)); ok {
+		t.Errorf("Expected foo to have expired")
+	}
+	cache.set("bar", &Service{ID: "bar"}, now, 0, 0)
+	if _, ok := cache.get("bar", now.Add(24*time.Hour)); !ok {
+		t.Errorf("Expected bar to be cached forever")
+	}
+}
+
+func TestServiceCacheSize(t *testing.T) {
+	cache := newServiceCache()
+	now := time.Date(2018, 7, 25, 1, 26, 17, 0, time.UTC)
+	for i, name := range []string{"a", "b", "c"} {
+		cache.set(name, &Service{ID: name}, now.Add(time.Duration(i)*time.Second), 0, 2)
+	}
+	if _, ok := cache.get("a", now); ok {
+		t.Errorf("Expected the oldest service to be evicted")
+	}
+	for _, name := range []string{"b", "c"} {
+		if _, ok := cache.get(name, now); !ok {
+			t.Errorf("Expected %s to be cached", name)
+		}
+	}
+}
+
+func TestServiceCacheCopies(t *testing.T) {
+	cache := newServiceCache()
+	now := time.Now()
+	credential := &Credential{ID: "1", CredentialPairs: map[string]string{"key": "value"}}
+	service := &Service{ID: "foo", Credentials: []*Credential{credential}}
+	cache.set("foo", service, now, 0, 0)
+	service.Enabled = true
+	credential.CredentialPairs["key"] = "changed"
+
+	cached, _ := cache.get("foo", now)
+	if cached.Enabled {
+		t.Errorf("Changing a service after caching it changed the cache")
+	}
+	cached.Credentials[0].CredentialPairs["key"] = "changed again"
+	cached, _ = cache.get("foo", now)
+	if cached.Credentials[0].CredentialPairs["key"] != "value" {
+		t.Errorf("Changing a cached service changed the cache, got %s", cached.Credentials[0].CredentialPairs["key"])
+	}
+}
+
+func TestGetServiceCache(t *testing.T) {
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Write([]byte(`{"id": "foo", "enabled": true}`))
+	}))
+	defer ts.Close()
+	c := createMockClient(ts.URL)
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.GetService("foo"); err != nil {
+			t.Fatalf("Could not get service: %s", err)
+		}
+	}
+	if hits != 1 {
+		t.Errorf("Expected 1 request, got %d", hits)
+	}
+	c.InvalidateService("foo")
+	c.GetService("foo")
+	if hits != 2 {
+		t.Errorf("Expected a request after invalidating the service, got %d requests", hits)
+	}
+	c.FlushServiceCache()
+	c.GetService("foo")
+	if hits != 3 {
+		t.Errorf("Expected a request after flushing the cache, got %d requests", hits)
+	}
+	c.DisableServiceCache = true
+	c.GetService("foo")
+	c.GetService("foo")
+	if hits != 5 {
+		t.Errorf("Expected a request every time with the cache disabled, got %d requests", hits)
+	}
+}
+
+func TestUpdateBypassesServiceCache(t *testing.T) {
+	var gets int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + r.URL.Path {
+		case "GET/v1/services/foo":
+			atomic.AddInt32(&gets, 1)
+			w.Write([]byte(`{"id": "foo", "enabled": false}`))
+		case "PUT/v1/grants/foo":
+			w.Write([]byte(`{"grants": {"encrypt_grant": true, "decrypt_grant": true}}`))
+		case "PUT/v1/services/foo":
+			w.Write([]byte(`{"id": "foo", "enabled": true, "revision": 2}`))
+		}
+	}))
+	defer ts.Close()
+	c := createMockClient(ts.URL)
+	if _, err := c.GetService("foo"); err != nil {
+		t.Fatalf("Could not get service: %s", err)
+	}
+	service, err := c.EnableService("foo")
+	if err != nil {
+		t.Fatalf("Could not enable service: %s", err)
+	}
+	if gets != 2 {
+		t.Errorf("Expected EnableService to fetch the service again, got %d requests", gets)
+	}
+	service.Enabled = false
+	cached, _ := c.GetService("foo")
+	if !cached.Enabled || cached.Revision != 2 {
+		t.Errorf("Expected the updated service to be cached, got %+v", cached)
+	}
+}
@@ -0,0 +1,55 @@
+package confidant
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestClientConcurrentUse is most useful with the race detector (go test -race).
+func TestClientConcurrentUse(t *testing.T) {
+	serviceName := "foo"
+	credential := Credential{ID: "1", Name: "name"}
+	service := Service{ID: serviceName, Credentials: []*Credential{&credential}}
+	grants := Grants{EncryptGrant: true, DecryptGrant: true}
+	responses := make(map[string]interface{})
+	responses["GET/v1/services"] = Services{Services: []Service{service}}
+	responses["GET/v1/services/"+serviceName] = service
+	responses["PUT/v1/services/"+serviceName] = service
+	responses["PUT/v1/grants/"+serviceName] = GrantsResponse{Grants: grants}
+	responses["GET/v1/credentials"] = CredentialResponse{Credentials: []Credential{credential}}
+	ts, c := CreateMockClientAndServer(responses, t)
+	defer ts.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(4)
+		go func() {
+			defer wg.Done()
+			if _, err := c.GetService(serviceName); err != nil {
+				t.Errorf("Could not get service: %s", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := c.GetServices(); err != nil {
+				t.Errorf("Could not get services: %s", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if err := c.AssignCredential(serviceName, "name"); err != nil {
+				t.Errorf("Could not assign credential: %s", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := c.EnableService(serviceName); err != nil {
+				t.Errorf("Could not enable service: %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if _, ok := c.cachedService(serviceName); !ok {
+		t.Errorf("client.services does not contain %s", serviceName)
+	}
+}
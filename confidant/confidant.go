@@ -2,23 +2,42 @@ package confidant
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/stripe/go-confidant-client/kmsauth"
 )
 
 func NewClient(url string, httpClient *http.Client, tokenGenerator *kmsauth.TokenGenerator) Client {
 	client := Client{
-		HttpClient:     httpClient,
-		TokenGenerator: tokenGenerator,
-		services:       make(map[string]*Service),
-		url:            url,
+		HttpClient:      httpClient,
+		TokenGenerator:  tokenGenerator,
+		RetryPolicy:     DefaultRetryPolicy(),
+		ServiceCacheTTL: DefaultServiceCacheTTL,
+		services:        newServiceCache(),
+		url:             url,
 	}
 	return client
 }
 
+// Client is a Confidant API client.
+// A Client is safe for concurrent use by multiple goroutines,
+// as long as its exported fields are not changed after it is first used.
 type Client struct {
 	HttpClient     *http.Client
 	TokenGenerator *kmsauth.TokenGenerator
-	services       map[string]*Service
-	url            string
+	// Authenticator authenticates requests. If nil, they are authenticated with tokens
+	// from TokenGenerator.
+	Authenticator Authenticator
+	// RetryPolicy decides whether failed requests are retried. If nil, they are not.
+	RetryPolicy RetryPolicy
+	// ServiceCacheTTL is how long GetService caches services for. Zero means forever.
+	ServiceCacheTTL time.Duration
+	// ServiceCacheSize is the maximum number of services cached. Zero means no limit.
+	ServiceCacheSize int
+	// DisableServiceCache makes GetService fetch services from Confidant every time.
+	DisableServiceCache bool
+	// UserAgent is sent as the User-Agent of requests, if set.
+	UserAgent string
+	services  *serviceCache
+	url       string
 }
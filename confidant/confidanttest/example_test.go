@@ -0,0 +1,27 @@
+package confidanttest_test
+
+import (
+	"fmt"
+
+	"github.com/stripe/go-confidant-client/confidant/confidanttest"
+)
+
+func Example() {
+	server := confidanttest.NewServer()
+	defer server.Close()
+	server.AddRoles("web")
+	server.AddCredential("db", map[string]string{"db_password": "hunter2"})
+
+	client := server.Client()
+	if _, err := client.CreateService("web", []string{"db"}); err != nil {
+		fmt.Println(err)
+		return
+	}
+	pairs, err := client.ServiceCredentials("web")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(pairs["db_password"])
+	// Output: hunter2
+}
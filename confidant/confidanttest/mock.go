@@ -0,0 +1,499 @@
+package confidanttest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/stripe/go-confidant-client/confidant"
+)
+
+// ErrNotMocked is returned (wrapped) by Mock's methods whose function isn't set.
+var ErrNotMocked = errors.New("Method is not mocked")
+
+// Call is a call to one of Mock's methods.
+type Call struct {
+	// Method is the name of the method, without "WithContext".
+	Method string
+	// Args are the arguments, without the context.
+	Args []interface{}
+}
+
+// Mock is a confidant.API for unit tests. Each method calls the function of the same name
+// in the Mock, or returns an error matching ErrNotMocked if it is nil, and records the call.
+// Methods without a context call the WithContext function with context.Background().
+// The functions should be set before Mock is used. It is safe to use from multiple goroutines.
+type Mock struct {
+	// Services
+	GetServicesFunc              func(ctx context.Context) (*confidant.Services, error)
+	GetServiceFunc               func(ctx context.Context, serviceName string) (*confidant.Service, error)
+	CreateServiceFunc            func(ctx context.Context, serviceName string, credentialNames []string) (*confidant.Service, error)
+	SetServiceCredentialsFunc    func(ctx context.Context, serviceName string, credentialNames []string) (*confidant.Service, error)
+	UpdateServiceCredentialsFunc func(ctx context.Context, serviceName string, addCredentialNames []string, removeCredentialNames []string) (*confidant.Service, error)
+	EnableServiceFunc            func(ctx context.Context, serviceName string) (*confidant.Service, error)
+	DisableServiceFunc           func(ctx context.Context, serviceName string) (*confidant.Service, error)
+	ServiceCredentialsFunc       func(ctx context.Context, serviceName string) (map[string]string, error)
+
+	// Credentials
+	FindCredentialsByNameFunc   func(ctx context.Context, names []string) ([]*confidant.Credential, error)
+	AssignCredentialFunc        func(ctx context.Context, serviceName string, credentialName string) error
+	UnassignCredentialFunc      func(ctx context.Context, serviceName string, credentialName string) error
+	GetCredentialFunc           func(ctx context.Context, id string) (*confidant.Credential, error)
+	CreateCredentialFunc        func(ctx context.Context, body *confidant.CredentialRequestBody) (*confidant.Credential, error)
+	UpdateCredentialFunc        func(ctx context.Context, id string, body *confidant.CredentialRequestBody) (*confidant.Credential, error)
+	ListCredentialRevisionsFunc func(ctx context.Context, id string) ([]*confidant.Credential, error)
+	RevertCredentialFunc        func(ctx context.Context, id string, revision int) (*confidant.Credential, error)
+
+	// Blind credentials
+	GetBlindCredentialsFunc           func(ctx context.Context) ([]*confidant.BlindCredential, error)
+	FindBlindCredentialsByNameFunc    func(ctx context.Context, names []string) ([]*confidant.BlindCredential, error)
+	GetBlindCredentialFunc            func(ctx context.Context, id string) (*confidant.BlindCredential, error)
+	CreateBlindCredentialFunc         func(ctx context.Context, body *confidant.BlindCredentialRequestBody) (*confidant.BlindCredential, error)
+	UpdateBlindCredentialFunc         func(ctx context.Context, id string, body *confidant.BlindCredentialRequestBody) (*confidant.BlindCredential, error)
+	SetServiceBlindCredentialsFunc    func(ctx context.Context, serviceName string, blindCredentialNames []string) (*confidant.Service, error)
+	UpdateServiceBlindCredentialsFunc func(ctx context.Context, serviceName string, addBlindCredentialNames []string, removeBlindCredentialNames []string) (*confidant.Service, error)
+	AssignBlindCredentialFunc         func(ctx context.Context, serviceName string, blindCredentialName string) error
+	UnassignBlindCredentialFunc       func(ctx context.Context, serviceName string, blindCredentialName string) error
+
+	// Grants
+	GetGrantsFunc    func(ctx context.Context, serviceName string) (*confidant.Grants, error)
+	EnsureGrantsFunc func(ctx context.Context, serviceName string) error
+
+	// Roles
+	CheckRoleFunc func(ctx context.Context, serviceName string) error
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+var _ confidant.API = (*Mock)(nil)
+
+// Calls returns the calls made to the mock, in order.
+func (m *Mock) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Call{}, m.calls...)
+}
+
+// CallsTo returns the calls made to method, in order.
+func (m *Mock) CallsTo(method string) []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var calls []Call
+	for _, call := range m.calls {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Reset forgets the calls made to the mock.
+func (m *Mock) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = nil
+}
+
+func (m *Mock) record(method string, args ...interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, Call{Method: method, Args: args})
+}
+
+func notMocked(method string) error {
+	return fmt.Errorf("%s: %w", method, ErrNotMocked)
+}
+
+// GetServices calls GetServicesFunc.
+func (m *Mock) GetServices() (*confidant.Services, error) {
+	return m.GetServicesWithContext(context.Background())
+}
+
+// GetServicesWithContext calls GetServicesFunc.
+func (m *Mock) GetServicesWithContext(ctx context.Context) (*confidant.Services, error) {
+	m.record("GetServices")
+	if m.GetServicesFunc == nil {
+		return nil, notMocked("GetServices")
+	}
+	return m.GetServicesFunc(ctx)
+}
+
+// GetService calls GetServiceFunc.
+func (m *Mock) GetService(serviceName string) (*confidant.Service, error) {
+	return m.GetServiceWithContext(context.Background(), serviceName)
+}
+
+// GetServiceWithContext calls GetServiceFunc.
+func (m *Mock) GetServiceWithContext(ctx context.Context, serviceName string) (*confidant.Service, error) {
+	m.record("GetService", serviceName)
+	if m.GetServiceFunc == nil {
+		return nil, notMocked("GetService")
+	}
+	return m.GetServiceFunc(ctx, serviceName)
+}
+
+// CreateService calls CreateServiceFunc.
+func (m *Mock) CreateService(serviceName string, credentialNames []string) (*confidant.Service, error) {
+	return m.CreateServiceWithContext(context.Background(), serviceName, credentialNames)
+}
+
+// CreateServiceWithContext calls CreateServiceFunc.
+func (m *Mock) CreateServiceWithContext(ctx context.Context, serviceName string, credentialNames []string) (*confidant.Service, error) {
+	m.record("CreateService", serviceName, credentialNames)
+	if m.CreateServiceFunc == nil {
+		return nil, notMocked("CreateService")
+	}
+	return m.CreateServiceFunc(ctx, serviceName, credentialNames)
+}
+
+// SetServiceCredentials calls SetServiceCredentialsFunc.
+func (m *Mock) SetServiceCredentials(serviceName string, credentialNames []string) (*confidant.Service, error) {
+	return m.SetServiceCredentialsWithContext(context.Background(), serviceName, credentialNames)
+}
+
+// SetServiceCredentialsWithContext calls SetServiceCredentialsFunc.
+func (m *Mock) SetServiceCredentialsWithContext(ctx context.Context, serviceName string, credentialNames []string) (*confidant.Service, error) {
+	m.record("SetServiceCredentials", serviceName, credentialNames)
+	if m.SetServiceCredentialsFunc == nil {
+		return nil, notMocked("SetServiceCredentials")
+	}
+	return m.SetServiceCredentialsFunc(ctx, serviceName, credentialNames)
+}
+
+// UpdateServiceCredentials calls UpdateServiceCredentialsFunc.
+func (m *Mock) UpdateServiceCredentials(serviceName string, addCredentialNames []string, removeCredentialNames []string) (*confidant.Service, error) {
+	return m.UpdateServiceCredentialsWithContext(context.Background(), serviceName, addCredentialNames, removeCredentialNames)
+}
+
+// UpdateServiceCredentialsWithContext calls UpdateServiceCredentialsFunc.
+func (m *Mock) UpdateServiceCredentialsWithContext(ctx context.Context, serviceName string, addCredentialNames []string, removeCredentialNames []string) (*confidant.Service, error) {
+	m.record("UpdateServiceCredentials", serviceName, addCredentialNames, removeCredentialNames)
+	if m.UpdateServiceCredentialsFunc == nil {
+		return nil, notMocked("UpdateServiceCredentials")
+	}
+	return m.UpdateServiceCredentialsFunc(ctx, serviceName, addCredentialNames, removeCredentialNames)
+}
+
+// EnableService calls EnableServiceFunc.
+func (m *Mock) EnableService(serviceName string) (*confidant.Service, error) {
+	return m.EnableServiceWithContext(context.Background(), serviceName)
+}
+
+// EnableServiceWithContext calls EnableServiceFunc.
+func (m *Mock) EnableServiceWithContext(ctx context.Context, serviceName string) (*confidant.Service, error) {
+	m.record("EnableService", serviceName)
+	if m.EnableServiceFunc == nil {
+		return nil, notMocked("EnableService")
+	}
+	return m.EnableServiceFunc(ctx, serviceName)
+}
+
+// DisableService calls DisableServiceFunc.
+func (m *Mock) DisableService(serviceName string) (*confidant.Service, error) {
+	return m.DisableServiceWithContext(context.Background(), serviceName)
+}
+
+// DisableServiceWithContext calls DisableServiceFunc.
+func (m *Mock) DisableServiceWithContext(ctx context.Context, serviceName string) (*confidant.Service, error) {
+	m.record("DisableService", serviceName)
+	if m.DisableServiceFunc == nil {
+		return nil, notMocked("DisableService")
+	}
+	return m.DisableServiceFunc(ctx, serviceName)
+}
+
+// ServiceCredentials calls ServiceCredentialsFunc.
+func (m *Mock) ServiceCredentials(serviceName string) (map[string]string, error) {
+	return m.ServiceCredentialsWithContext(context.Background(), serviceName)
+}
+
+// ServiceCredentialsWithContext calls ServiceCredentialsFunc.
+func (m *Mock) ServiceCredentialsWithContext(ctx context.Context, serviceName string) (map[string]string, error) {
+	m.record("ServiceCredentials", serviceName)
+	if m.ServiceCredentialsFunc == nil {
+		return nil, notMocked("ServiceCredentials")
+	}
+	return m.ServiceCredentialsFunc(ctx, serviceName)
+}
+
+// FindCredentialsByName calls FindCredentialsByNameFunc.
+func (m *Mock) FindCredentialsByName(names []string) ([]*confidant.Credential, error) {
+	return m.FindCredentialsByNameWithContext(context.Background(), names)
+}
+
+// FindCredentialsByNameWithContext calls FindCredentialsByNameFunc.
+func (m *Mock) FindCredentialsByNameWithContext(ctx context.Context, names []string) ([]*confidant.Credential, error) {
+	m.record("FindCredentialsByName", names)
+	if m.FindCredentialsByNameFunc == nil {
+		return nil, notMocked("FindCredentialsByName")
+	}
+	return m.FindCredentialsByNameFunc(ctx, names)
+}
+
+// AssignCredential calls AssignCredentialFunc.
+func (m *Mock) AssignCredential(serviceName string, credentialName string) error {
+	return m.AssignCredentialWithContext(context.Background(), serviceName, credentialName)
+}
+
+// AssignCredentialWithContext calls AssignCredentialFunc.
+func (m *Mock) AssignCredentialWithContext(ctx context.Context, serviceName string, credentialName string) error {
+	m.record("AssignCredential", serviceName, credentialName)
+	if m.AssignCredentialFunc == nil {
+		return notMocked("AssignCredential")
+	}
+	return m.AssignCredentialFunc(ctx, serviceName, credentialName)
+}
+
+// UnassignCredential calls UnassignCredentialFunc.
+func (m *Mock) UnassignCredential(serviceName string, credentialName string) error {
+	return m.UnassignCredentialWithContext(context.Background(), serviceName, credentialName)
+}
+
+// UnassignCredentialWithContext calls UnassignCredentialFunc.
+func (m *Mock) UnassignCredentialWithContext(ctx context.Context, serviceName string, credentialName string) error {
+	m.record("UnassignCredential", serviceName, credentialName)
+	if m.UnassignCredentialFunc == nil {
+		return notMocked("UnassignCredential")
+	}
+	return m.UnassignCredentialFunc(ctx, serviceName, credentialName)
+}
+
+// GetCredential calls GetCredentialFunc.
+func (m *Mock) GetCredential(id string) (*confidant.Credential, error) {
+	return m.GetCredentialWithContext(context.Background(), id)
+}
+
+// GetCredentialWithContext calls GetCredentialFunc.
+func (m *Mock) GetCredentialWithContext(ctx context.Context, id string) (*confidant.Credential, error) {
+	m.record("GetCredential", id)
+	if m.GetCredentialFunc == nil {
+		return nil, notMocked("GetCredential")
+	}
+	return m.GetCredentialFunc(ctx, id)
+}
+
+// CreateCredential calls CreateCredentialFunc.
+func (m *Mock) CreateCredential(body *confidant.CredentialRequestBody) (*confidant.Credential, error) {
+	return m.CreateCredentialWithContext(context.Background(), body)
+}
+
+// CreateCredentialWithContext calls CreateCredentialFunc.
+func (m *Mock) CreateCredentialWithContext(ctx context.Context, body *confidant.CredentialRequestBody) (*confidant.Credential, error) {
+	m.record("CreateCredential", body)
+	if m.CreateCredentialFunc == nil {
+		return nil, notMocked("CreateCredential")
+	}
+	return m.CreateCredentialFunc(ctx, body)
+}
+
+// UpdateCredential calls UpdateCredentialFunc.
+func (m *Mock) UpdateCredential(id string, body *confidant.CredentialRequestBody) (*confidant.Credential, error) {
+	return m.UpdateCredentialWithContext(context.Background(), id, body)
+}
+
+// UpdateCredentialWithContext calls UpdateCredentialFunc.
+func (m *Mock) UpdateCredentialWithContext(ctx context.Context, id string, body *confidant.CredentialRequestBody) (*confidant.Credential, error) {
+	m.record("UpdateCredential", id, body)
+	if m.UpdateCredentialFunc == nil {
+		return nil, notMocked("UpdateCredential")
+	}
+	return m.UpdateCredentialFunc(ctx, id, body)
+}
+
+// ListCredentialRevisions calls ListCredentialRevisionsFunc.
+func (m *Mock) ListCredentialRevisions(id string) ([]*confidant.Credential, error) {
+	return m.ListCredentialRevisionsWithContext(context.Background(), id)
+}
+
+// ListCredentialRevisionsWithContext calls ListCredentialRevisionsFunc.
+func (m *Mock) ListCredentialRevisionsWithContext(ctx context.Context, id string) ([]*confidant.Credential, error) {
+	m.record("ListCredentialRevisions", id)
+	if m.ListCredentialRevisionsFunc == nil {
+		return nil, notMocked("ListCredentialRevisions")
+	}
+	return m.ListCredentialRevisionsFunc(ctx, id)
+}
+
+// RevertCredential calls RevertCredentialFunc.
+func (m *Mock) RevertCredential(id string, revision int) (*confidant.Credential, error) {
+	return m.RevertCredentialWithContext(context.Background(), id, revision)
+}
+
+// RevertCredentialWithContext calls RevertCredentialFunc.
+func (m *Mock) RevertCredentialWithContext(ctx context.Context, id string, revision int) (*confidant.Credential, error) {
+	m.record("RevertCredential", id, revision)
+	if m.RevertCredentialFunc == nil {
+		return nil, notMocked("RevertCredential")
+	}
+	return m.RevertCredentialFunc(ctx, id, revision)
+}
+
+// GetBlindCredentials calls GetBlindCredentialsFunc.
+func (m *Mock) GetBlindCredentials() ([]*confidant.BlindCredential, error) {
+	return m.GetBlindCredentialsWithContext(context.Background())
+}
+
+// GetBlindCredentialsWithContext calls GetBlindCredentialsFunc.
+func (m *Mock) GetBlindCredentialsWithContext(ctx context.Context) ([]*confidant.BlindCredential, error) {
+	m.record("GetBlindCredentials")
+	if m.GetBlindCredentialsFunc == nil {
+		return nil, notMocked("GetBlindCredentials")
+	}
+	return m.GetBlindCredentialsFunc(ctx)
+}
+
+// FindBlindCredentialsByName calls FindBlindCredentialsByNameFunc.
+func (m *Mock) FindBlindCredentialsByName(names []string) ([]*confidant.BlindCredential, error) {
+	return m.FindBlindCredentialsByNameWithContext(context.Background(), names)
+}
+
+// FindBlindCredentialsByNameWithContext calls FindBlindCredentialsByNameFunc.
+func (m *Mock) FindBlindCredentialsByNameWithContext(ctx context.Context, names []string) ([]*confidant.BlindCredential, error) {
+	m.record("FindBlindCredentialsByName", names)
+	if m.FindBlindCredentialsByNameFunc == nil {
+		return nil, notMocked("FindBlindCredentialsByName")
+	}
+	return m.FindBlindCredentialsByNameFunc(ctx, names)
+}
+
+// GetBlindCredential calls GetBlindCredentialFunc.
+func (m *Mock) GetBlindCredential(id string) (*confidant.BlindCredential, error) {
+	return m.GetBlindCredentialWithContext(context.Background(), id)
+}
+
+// GetBlindCredentialWithContext calls GetBlindCredentialFunc.
+func (m *Mock) GetBlindCredentialWithContext(ctx context.Context, id string) (*confidant.BlindCredential, error) {
+	m.record("GetBlindCredential", id)
+	if m.GetBlindCredentialFunc == nil {
+		return nil, notMocked("GetBlindCredential")
+	}
+	return m.GetBlindCredentialFunc(ctx, id)
+}
+
+// CreateBlindCredential calls CreateBlindCredentialFunc.
+func (m *Mock) CreateBlindCredential(body *confidant.BlindCredentialRequestBody) (*confidant.BlindCredential, error) {
+	return m.CreateBlindCredentialWithContext(context.Background(), body)
+}
+
+// CreateBlindCredentialWithContext calls CreateBlindCredentialFunc.
+func (m *Mock) CreateBlindCredentialWithContext(ctx context.Context, body *confidant.BlindCredentialRequestBody) (*confidant.BlindCredential, error) {
+	m.record("CreateBlindCredential", body)
+	if m.CreateBlindCredentialFunc == nil {
+		return nil, notMocked("CreateBlindCredential")
+	}
+	return m.CreateBlindCredentialFunc(ctx, body)
+}
+
+// UpdateBlindCredential calls UpdateBlindCredentialFunc.
+func (m *Mock) UpdateBlindCredential(id string, body *confidant.BlindCredentialRequestBody) (*confidant.BlindCredential, error) {
+	return m.UpdateBlindCredentialWithContext(context.Background(), id, body)
+}
+
+// UpdateBlindCredentialWithContext calls UpdateBlindCredentialFunc.
+func (m *Mock) UpdateBlindCredentialWithContext(ctx context.Context, id string, body *confidant.BlindCredentialRequestBody) (*confidant.BlindCredential, error) {
+	m.record("UpdateBlindCredential", id, body)
+	if m.UpdateBlindCredentialFunc == nil {
+		return nil, notMocked("UpdateBlindCredential")
+	}
+	return m.UpdateBlindCredentialFunc(ctx, id, body)
+}
+
+// SetServiceBlindCredentials calls SetServiceBlindCredentialsFunc.
+func (m *Mock) SetServiceBlindCredentials(serviceName string, blindCredentialNames []string) (*confidant.Service, error) {
+	return m.SetServiceBlindCredentialsWithContext(context.Background(), serviceName, blindCredentialNames)
+}
+
+// SetServiceBlindCredentialsWithContext calls SetServiceBlindCredentialsFunc.
+func (m *Mock) SetServiceBlindCredentialsWithContext(ctx context.Context, serviceName string, blindCredentialNames []string) (*confidant.Service, error) {
+	m.record("SetServiceBlindCredentials", serviceName, blindCredentialNames)
+	if m.SetServiceBlindCredentialsFunc == nil {
+		return nil, notMocked("SetServiceBlindCredentials")
+	}
+	return m.SetServiceBlindCredentialsFunc(ctx, serviceName, blindCredentialNames)
+}
+
+// UpdateServiceBlindCredentials calls UpdateServiceBlindCredentialsFunc.
+func (m *Mock) UpdateServiceBlindCredentials(serviceName string, addBlindCredentialNames []string, removeBlindCredentialNames []string) (*confidant.Service, error) {
+	return m.UpdateServiceBlindCredentialsWithContext(context.Background(), serviceName, addBlindCredentialNames, removeBlindCredentialNames)
+}
+
+// UpdateServiceBlindCredentialsWithContext calls UpdateServiceBlindCredentialsFunc.
+func (m *Mock) UpdateServiceBlindCredentialsWithContext(ctx context.Context, serviceName string, addBlindCredentialNames []string, removeBlindCredentialNames []string) (*confidant.Service, error) {
+	m.record("UpdateServiceBlindCredentials", serviceName, addBlindCredentialNames, removeBlindCredentialNames)
+	if m.UpdateServiceBlindCredentialsFunc == nil {
+		return nil, notMocked("UpdateServiceBlindCredentials")
+	}
+	return m.UpdateServiceBlindCredentialsFunc(ctx, serviceName, addBlindCredentialNames, removeBlindCredentialNames)
+}
+
+// AssignBlindCredential calls AssignBlindCredentialFunc.
+func (m *Mock) AssignBlindCredential(serviceName string, blindCredentialName string) error {
+	return m.AssignBlindCredentialWithContext(context.Background(), serviceName, blindCredentialName)
+}
+
+// AssignBlindCredentialWithContext calls AssignBlindCredentialFunc.
+func (m *Mock) AssignBlindCredentialWithContext(ctx context.Context, serviceName string, blindCredentialName string) error {
+	m.record("AssignBlindCredential", serviceName, blindCredentialName)
+	if m.AssignBlindCredentialFunc == nil {
+		return notMocked("AssignBlindCredential")
+	}
+	return m.AssignBlindCredentialFunc(ctx, serviceName, blindCredentialName)
+}
+
+// UnassignBlindCredential calls UnassignBlindCredentialFunc.
+func (m *Mock) UnassignBlindCredential(serviceName string, blindCredentialName string) error {
+	return m.UnassignBlindCredentialWithContext(context.Background(), serviceName, blindCredentialName)
+}
+
+// UnassignBlindCredentialWithContext calls UnassignBlindCredentialFunc.
+func (m *Mock) UnassignBlindCredentialWithContext(ctx context.Context, serviceName string, blindCredentialName string) error {
+	m.record("UnassignBlindCredential", serviceName, blindCredentialName)
+	if m.UnassignBlindCredentialFunc == nil {
+		return notMocked("UnassignBlindCredential")
+	}
+	return m.UnassignBlindCredentialFunc(ctx, serviceName, blindCredentialName)
+}
+
+// GetGrants calls GetGrantsFunc.
+func (m *Mock) GetGrants(serviceName string) (*confidant.Grants, error) {
+	return m.GetGrantsWithContext(context.Background(), serviceName)
+}
+
+// GetGrantsWithContext calls GetGrantsFunc.
+func (m *Mock) GetGrantsWithContext(ctx context.Context, serviceName string) (*confidant.Grants, error) {
+	m.record("GetGrants", serviceName)
+	if m.GetGrantsFunc == nil {
+		return nil, notMocked("GetGrants")
+	}
+	return m.GetGrantsFunc(ctx, serviceName)
+}
+
+// EnsureGrants calls EnsureGrantsFunc.
+func (m *Mock) EnsureGrants(serviceName string) error {
+	return m.EnsureGrantsWithContext(context.Background(), serviceName)
+}
+
+// EnsureGrantsWithContext calls EnsureGrantsFunc.
+func (m *Mock) EnsureGrantsWithContext(ctx context.Context, serviceName string) error {
+	m.record("EnsureGrants", serviceName)
+	if m.EnsureGrantsFunc == nil {
+		return notMocked("EnsureGrants")
+	}
+	return m.EnsureGrantsFunc(ctx, serviceName)
+}
+
+// CheckRole calls CheckRoleFunc.
+func (m *Mock) CheckRole(serviceName string) error {
+	return m.CheckRoleWithContext(context.Background(), serviceName)
+}
+
+// CheckRoleWithContext calls CheckRoleFunc.
+func (m *Mock) CheckRoleWithContext(ctx context.Context, serviceName string) error {
+	m.record("CheckRole", serviceName)
+	if m.CheckRoleFunc == nil {
+		return notMocked("CheckRole")
+	}
+	return m.CheckRoleFunc(ctx, serviceName)
+}
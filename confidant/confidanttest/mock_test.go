@@ -0,0 +1,68 @@
+package confidanttest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/stripe/go-confidant-client/confidant"
+)
+
+// rotate is code under test that depends on confidant.API.
+func rotate(api confidant.API, id string, pairs map[string]string) error {
+	credential, err := api.GetCredential(id)
+	if err != nil {
+		return err
+	}
+	_, err = api.UpdateCredential(id, &confidant.CredentialRequestBody{
+		Name:            credential.Name,
+		CredentialPairs: pairs,
+		Enabled:         credential.Enabled,
+	})
+	return err
+}
+
+func TestMock(t *testing.T) {
+	mock := &Mock{
+		GetCredentialFunc: func(ctx context.Context, id string) (*confidant.Credential, error) {
+			return &confidant.Credential{ID: id, Name: "db", Enabled: true}, nil
+		},
+		UpdateCredentialFunc: func(ctx context.Context, id string, body *confidant.CredentialRequestBody) (*confidant.Credential, error) {
+			return &confidant.Credential{ID: id, Name: body.Name, CredentialPairs: body.CredentialPairs}, nil
+		},
+	}
+	if err := rotate(mock, "abc", map[string]string{"password": "new"}); err != nil {
+		t.Fatalf("Could not rotate: %s", err)
+	}
+
+	calls := mock.Calls()
+	if len(calls) != 2 || calls[0].Method != "GetCredential" || calls[1].Method != "UpdateCredential" {
+		t.Fatalf("Expected GetCredential and UpdateCredential calls, got %+v", calls)
+	}
+	body := calls[1].Args[1].(*confidant.CredentialRequestBody)
+	if calls[1].Args[0] != "abc" || body.Name != "db" || !reflect.DeepEqual(body.CredentialPairs, map[string]string{"password": "new"}) {
+		t.Errorf("Expected the credential to be updated with the new pairs, got %+v", calls[1].Args)
+	}
+	if calls := mock.CallsTo("GetCredential"); len(calls) != 1 || calls[0].Args[0] != "abc" {
+		t.Errorf("Expected one GetCredential call, got %+v", calls)
+	}
+
+	mock.Reset()
+	if len(mock.Calls()) != 0 {
+		t.Errorf("Expected no calls after Reset")
+	}
+}
+
+func TestMockNotMocked(t *testing.T) {
+	mock := &Mock{}
+	if _, err := mock.GetServiceWithContext(context.Background(), "web"); !errors.Is(err, ErrNotMocked) {
+		t.Errorf("Expected ErrNotMocked, got %v", err)
+	}
+	if err := mock.EnsureGrants("web"); !errors.Is(err, ErrNotMocked) {
+		t.Errorf("Expected ErrNotMocked, got %v", err)
+	}
+	if calls := mock.CallsTo("GetService"); len(calls) != 1 || calls[0].Args[0] != "web" {
+		t.Errorf("Expected the call to be recorded, got %+v", calls)
+	}
+}
@@ -0,0 +1,703 @@
+// Package confidanttest provides an in-memory Confidant server for testing code
+// that uses the confidant package, without a real Confidant or AWS.
+//
+//	server := confidanttest.NewServer()
+//	defer server.Close()
+//	server.AddRoles("my-service")
+//	client := server.Client()
+//	service, err := client.CreateService("my-service", nil)
+package confidanttest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stripe/go-confidant-client/confidant"
+	"github.com/stripe/go-confidant-client/kmsauth"
+	"github.com/stripe/go-confidant-client/kmsauth/kmstest"
+	"github.com/stripe/go-confidant-client/kmsauth/kmsv1"
+)
+
+const (
+	// AuthKey is the alias of the KMS key tokens for the server are encrypted with.
+	AuthKey = "alias/confidant-authnz"
+	// AuthTo is the name of the server in the tokens' auth context.
+	AuthTo = "confidant"
+	// AuthFrom is the name Client authenticates as.
+	AuthFrom = "confidanttest"
+	// Region is the AWS region of the server's KMS.
+	Region = "us-east-1"
+)
+
+// Server is a Confidant server that keeps services, credentials, blind credentials,
+// credential revisions, grants and roles in memory, and implements the v1 API the confidant
+// package uses. Requests must have X-Auth-From and X-Auth-Token headers, and the token is
+// validated with Validator if it is set. It is safe to use from multiple goroutines.
+type Server struct {
+	// URL is the base URL of the server.
+	URL string
+	// KMS is the in-memory KMS that Client's tokens are encrypted with.
+	KMS *kmstest.KMS
+	// Validator validates tokens. If nil, any token is accepted.
+	Validator *kmsauth.TokenValidator
+
+	server *httptest.Server
+
+	mu               sync.Mutex
+	roles            []string
+	services         map[string]*serviceRecord
+	credentials      map[string][]confidant.Credential
+	blindCredentials map[string]*confidant.BlindCredential
+	grants           map[string]confidant.Grants
+	failures         map[string]*Failure
+	requests         []Request
+}
+
+// Failure is an error response the server returns instead of handling a request.
+type Failure struct {
+	// StatusCode is the response's status code. If zero, http.StatusInternalServerError.
+	StatusCode int
+	// Message is the "error" field of the response body.
+	Message string
+	// Times is how many requests fail. Zero means every request fails until ClearFailures is called.
+	Times int
+}
+
+// Request is a request the server received.
+type Request struct {
+	Method string
+	Path   string
+	// Username is the X-Auth-From header.
+	Username string
+	Body     []byte
+}
+
+type serviceRecord struct {
+	ID                 string
+	Account            string
+	Enabled            bool
+	Revision           int
+	CredentialIDs      []string
+	BlindCredentialIDs []string
+	ModifiedBy         string
+	ModifiedDate       string
+}
+
+// NewServer starts a Server whose Validator accepts tokens encrypted for AuthTo with AuthKey by KMS.
+// Call Close when done with it.
+func NewServer() *Server {
+	fake := kmstest.New()
+	fake.AddKey(AuthKey)
+	validator := kmsauth.NewTokenValidator(AuthKey, AuthTo, Region, kmsauth.WithDecrypter(kmsv1.New(fake)))
+	s := &Server{
+		KMS:              fake,
+		Validator:        &validator,
+		services:         make(map[string]*serviceRecord),
+		credentials:      make(map[string][]confidant.Credential),
+		blindCredentials: make(map[string]*confidant.BlindCredential),
+		grants:           make(map[string]confidant.Grants),
+		failures:         make(map[string]*Failure),
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.server.URL
+	return s
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Client returns a client for the server, which authenticates as AuthFrom with tokens from KMS.
+// opts are applied after the server's, so they can replace its settings.
+func (s *Server) Client(opts ...confidant.Option) *confidant.Client {
+	opts = append([]confidant.Option{
+		confidant.WithAuth(AuthKey, AuthTo, AuthFrom, "user", Region),
+		confidant.WithKMSClient(s.KMS),
+	}, opts...)
+	client, err := confidant.New(s.URL, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return client
+}
+
+// AddRoles adds IAM roles, which are listed by GET /v1/roles.
+func (s *Server) AddRoles(roles ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.roles = append(s.roles, roles...)
+}
+
+// AddCredential creates a credential and returns it.
+func (s *Server) AddCredential(name string, pairs map[string]string) *confidant.Credential {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	credential := s.createCredential(confidant.CredentialRequestBody{Name: name, CredentialPairs: pairs, Enabled: true}, AuthFrom)
+	return &credential
+}
+
+// AddBlindCredential creates a blind credential, with a new ID, and returns it.
+func (s *Server) AddBlindCredential(credential confidant.BlindCredential) *confidant.BlindCredential {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	credential.ID = newID()
+	credential.Revision = 1
+	credential.ModifiedBy = AuthFrom
+	credential.ModifiedDate = now()
+	s.blindCredentials[credential.ID] = &credential
+	copied := credential
+	return &copied
+}
+
+// AddService creates an enabled service with the credentials and blind credentials with the given IDs,
+// and adds an IAM role and grants for it.
+func (s *Server) AddService(id string, credentialIDs []string, blindCredentialIDs []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.roles = append(s.roles, id)
+	s.services[id] = &serviceRecord{
+		ID:                 id,
+		Enabled:            true,
+		Revision:           1,
+		CredentialIDs:      append([]string{}, credentialIDs...),
+		BlindCredentialIDs: append([]string{}, blindCredentialIDs...),
+		ModifiedBy:         AuthFrom,
+		ModifiedDate:       now(),
+	}
+	s.grants[id] = confidant.Grants{EncryptGrant: true, DecryptGrant: true}
+}
+
+// Service returns a service with its credentials, as GET /v1/services/id would.
+func (s *Server) Service(id string) (*confidant.Service, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.services[id]
+	if !ok {
+		return nil, false
+	}
+	service := s.expandService(record)
+	return &service, true
+}
+
+// Credential returns the latest revision of a credential.
+func (s *Server) Credential(id string) (*confidant.Credential, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	revisions, ok := s.credentials[id]
+	if !ok {
+		return nil, false
+	}
+	credential := copyCredential(revisions[len(revisions)-1])
+	return &credential, true
+}
+
+// Grants returns the KMS grants of a service.
+func (s *Server) Grants(id string) confidant.Grants {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.grants[id]
+}
+
+// Fail makes requests with method to path, such as "GET" and "/v1/services/foo", fail.
+// An empty method matches every method.
+func (s *Server) Fail(method, path string, failure Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if failure.StatusCode == 0 {
+		failure.StatusCode = http.StatusInternalServerError
+	}
+	s.failures[method+" "+path] = &failure
+}
+
+// ClearFailures removes the failures added by Fail.
+func (s *Server) ClearFailures() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = make(map[string]*Failure)
+}
+
+// Requests returns the requests the server has received, in order.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request{}, s.requests...)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Could not read request body.")
+		return
+	}
+	username := r.Header.Get("X-Auth-From")
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{Method: r.Method, Path: r.URL.Path, Username: username, Body: body})
+	failure := s.failure(r.Method, r.URL.Path)
+	s.mu.Unlock()
+	if failure != nil {
+		writeError(w, failure.StatusCode, failure.Message)
+		return
+	}
+
+	from, ok := s.authenticate(r)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "Not authorized.")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status, response := s.route(r.Method, strings.Split(strings.Trim(r.URL.Path, "/"), "/"), body, from)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// failure returns the failure for a request, and counts it. s.mu must be held.
+func (s *Server) failure(method, path string) *Failure {
+	for _, key := range []string{method + " " + path, " " + path} {
+		failure, ok := s.failures[key]
+		if !ok {
+			continue
+		}
+		if failure.Times > 0 {
+			failure.Times--
+			if failure.Times == 0 {
+				delete(s.failures, key)
+			}
+		}
+		copied := *failure
+		return &copied
+	}
+	return nil
+}
+
+// authenticate checks the auth headers, and returns the name the token is from.
+func (s *Server) authenticate(r *http.Request) (string, bool) {
+	username := r.Header.Get("X-Auth-From")
+	token := r.Header.Get("X-Auth-Token")
+	if username == "" || token == "" {
+		return "", false
+	}
+	if s.Validator == nil {
+		_, _, from, err := kmsauth.ParseUsername(username)
+		return from, err == nil
+	}
+	principal, err := s.Validator.ValidateTokenWithContext(r.Context(), username, token)
+	if err != nil {
+		return "", false
+	}
+	return principal.Username, true
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse{Error: message})
+}
+
+var notFound = errorResponse{Error: "Not found."}
+
+// route handles a request and returns the status code and response body. s.mu must be held.
+func (s *Server) route(method string, path []string, body []byte, from string) (int, interface{}) {
+	if len(path) < 2 || path[0] != "v1" {
+		return http.StatusNotFound, notFound
+	}
+	switch {
+	case method == "GET" && len(path) == 2 && path[1] == "roles":
+		return http.StatusOK, confidant.Roles{Result: true, Roles: append([]string{}, s.roles...)}
+	case path[1] == "services":
+		return s.routeServices(method, path[2:], body, from)
+	case path[1] == "credentials":
+		return s.routeCredentials(method, path[2:], body, from)
+	case method == "GET" && len(path) == 4 && path[1] == "archive" && path[2] == "credentials":
+		return s.credentialRevisions(path[3])
+	case path[1] == "blind_credentials":
+		return s.routeBlindCredentials(method, path[2:], body, from)
+	case path[1] == "grants" && len(path) == 3:
+		return s.routeGrants(method, path[2])
+	}
+	return http.StatusNotFound, notFound
+}
+
+func (s *Server) routeServices(method string, path []string, body []byte, from string) (int, interface{}) {
+	switch {
+	case method == "GET" && len(path) == 0:
+		services := confidant.Services{Services: []confidant.Service{}}
+		for _, id := range s.serviceIDs() {
+			record := s.services[id]
+			services.Services = append(services.Services, confidant.Service{
+				ID:           record.ID,
+				Account:      record.Account,
+				Enabled:      record.Enabled,
+				Revision:     record.Revision,
+				ModifiedBy:   record.ModifiedBy,
+				ModifiedDate: record.ModifiedDate,
+			})
+		}
+		return http.StatusOK, services
+	case method == "GET" && len(path) == 1:
+		record, ok := s.services[path[0]]
+		if !ok {
+			return http.StatusNotFound, notFound
+		}
+		return http.StatusOK, s.expandService(record)
+	case method == "PUT" && len(path) == 1:
+		var request confidant.RequestBody
+		if err := json.Unmarshal(body, &request); err != nil {
+			return http.StatusBadRequest, errorResponse{Error: "Request body must be JSON."}
+		}
+		return s.putService(path[0], request, from)
+	}
+	return http.StatusMethodNotAllowed, errorResponse{Error: "Method not allowed."}
+}
+
+// serviceResponse is the response to PUT /v1/services/id. It has the service's fields,
+// which SetServiceCredentials and similar methods read, and the service under "service",
+// which CreateService reads.
+type serviceResponse struct {
+	confidant.Service
+	Result bool              `json:"result"`
+	Nested confidant.Service `json:"service"`
+}
+
+func (s *Server) putService(id string, request confidant.RequestBody, from string) (int, interface{}) {
+	for _, credentialID := range request.Credentials {
+		if _, ok := s.credentials[credentialID]; !ok {
+			return http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("Credential %s does not exist.", credentialID)}
+		}
+	}
+	for _, credentialID := range request.BlindCredentials {
+		if _, ok := s.blindCredentials[credentialID]; !ok {
+			return http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("Blind credential %s does not exist.", credentialID)}
+		}
+	}
+	if conflicts := s.conflicts(request.Credentials, request.BlindCredentials); len(conflicts) != 0 {
+		return http.StatusBadRequest, struct {
+			Error     string              `json:"error"`
+			Conflicts map[string][]string `json:"conflicts"`
+		}{"Conflicting key pairs in mapped service.", conflicts}
+	}
+	record, ok := s.services[id]
+	if !ok {
+		record = &serviceRecord{ID: id}
+		s.services[id] = record
+	}
+	record.Account = request.Account
+	record.Enabled = request.Enabled
+	record.Revision++
+	record.CredentialIDs = append([]string{}, request.Credentials...)
+	record.BlindCredentialIDs = append([]string{}, request.BlindCredentials...)
+	record.ModifiedBy = from
+	record.ModifiedDate = now()
+	service := s.expandService(record)
+	return http.StatusOK, serviceResponse{Service: service, Result: true, Nested: service}
+}
+
+// conflicts returns the credential pair keys that more than one of the credentials has,
+// mapped to the IDs of their credentials.
+func (s *Server) conflicts(credentialIDs, blindCredentialIDs []string) map[string][]string {
+	owners := make(map[string][]string)
+	for _, id := range credentialIDs {
+		revisions := s.credentials[id]
+		for key := range revisions[len(revisions)-1].CredentialPairs {
+			owners[key] = append(owners[key], id)
+		}
+	}
+	for _, id := range blindCredentialIDs {
+		for _, key := range s.blindCredentials[id].CredentialKeys {
+			owners[key] = append(owners[key], id)
+		}
+	}
+	conflicts := make(map[string][]string)
+	for key, ids := range owners {
+		if len(ids) > 1 {
+			conflicts[key] = ids
+		}
+	}
+	return conflicts
+}
+
+// expandService returns a service with its credentials and blind credentials. s.mu must be held.
+func (s *Server) expandService(record *serviceRecord) confidant.Service {
+	service := confidant.Service{
+		ID:               record.ID,
+		Account:          record.Account,
+		Enabled:          record.Enabled,
+		Revision:         record.Revision,
+		Credentials:      []*confidant.Credential{},
+		BlindCredentials: []*confidant.BlindCredential{},
+		ModifiedBy:       record.ModifiedBy,
+		ModifiedDate:     record.ModifiedDate,
+	}
+	for _, id := range record.CredentialIDs {
+		if revisions, ok := s.credentials[id]; ok {
+			credential := copyCredential(revisions[len(revisions)-1])
+			service.Credentials = append(service.Credentials, &credential)
+		}
+	}
+	for _, id := range record.BlindCredentialIDs {
+		if credential, ok := s.blindCredentials[id]; ok {
+			copied := *credential
+			service.BlindCredentials = append(service.BlindCredentials, &copied)
+		}
+	}
+	return service
+}
+
+func (s *Server) serviceIDs() []string {
+	ids := make([]string, 0, len(s.services))
+	for id := range s.services {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (s *Server) routeCredentials(method string, path []string, body []byte, from string) (int, interface{}) {
+	switch {
+	case method == "GET" && len(path) == 0:
+		response := confidant.CredentialResponse{Result: true, Credentials: []confidant.Credential{}}
+		for _, id := range s.credentialIDs() {
+			revisions := s.credentials[id]
+			credential := copyCredential(revisions[len(revisions)-1])
+			// Like Confidant, the list doesn't include credential pairs.
+			credential.CredentialPairs = nil
+			response.Credentials = append(response.Credentials, credential)
+		}
+		return http.StatusOK, response
+	case method == "GET" && len(path) == 1:
+		revisions, ok := s.credentials[path[0]]
+		if !ok {
+			return http.StatusNotFound, notFound
+		}
+		return http.StatusOK, copyCredential(revisions[len(revisions)-1])
+	case method == "POST" && len(path) == 0:
+		var request confidant.CredentialRequestBody
+		if err := json.Unmarshal(body, &request); err != nil {
+			return http.StatusBadRequest, errorResponse{Error: "Request body must be JSON."}
+		}
+		if message := validateCredential(request.Name, len(request.CredentialPairs)); message != "" {
+			return http.StatusBadRequest, errorResponse{Error: message}
+		}
+		return http.StatusOK, s.createCredential(request, from)
+	case method == "PUT" && len(path) == 1:
+		revisions, ok := s.credentials[path[0]]
+		if !ok {
+			return http.StatusNotFound, notFound
+		}
+		var request confidant.CredentialRequestBody
+		if err := json.Unmarshal(body, &request); err != nil {
+			return http.StatusBadRequest, errorResponse{Error: "Request body must be JSON."}
+		}
+		if message := validateCredential(request.Name, len(request.CredentialPairs)); message != "" {
+			return http.StatusBadRequest, errorResponse{Error: message}
+		}
+		current := revisions[len(revisions)-1]
+		return http.StatusOK, s.addRevision(confidant.Credential{
+			ID:              current.ID,
+			Name:            request.Name,
+			CredentialPairs: request.CredentialPairs,
+			Metadata:        request.Metadata,
+			Documentation:   request.Documentation,
+			Enabled:         request.Enabled,
+		}, from)
+	case method == "PUT" && len(path) == 2:
+		revisions, ok := s.credentials[path[0]]
+		if !ok {
+			return http.StatusNotFound, notFound
+		}
+		revision, err := strconv.Atoi(path[1])
+		if err != nil || revision < 1 || revision > len(revisions) {
+			return http.StatusNotFound, notFound
+		}
+		old, current := revisions[revision-1], revisions[len(revisions)-1]
+		if sameCredential(old, current) {
+			return http.StatusBadRequest, errorResponse{Error: "No difference between old and new credential."}
+		}
+		return http.StatusOK, s.addRevision(old, from)
+	}
+	return http.StatusMethodNotAllowed, errorResponse{Error: "Method not allowed."}
+}
+
+func (s *Server) credentialRevisions(id string) (int, interface{}) {
+	revisions, ok := s.credentials[id]
+	if !ok {
+		return http.StatusNotFound, notFound
+	}
+	response := confidant.CredentialRevisionsResponse{Revisions: make([]confidant.Credential, 0, len(revisions))}
+	// Like Confidant, the newest revision is first.
+	for i := len(revisions) - 1; i >= 0; i-- {
+		response.Revisions = append(response.Revisions, copyCredential(revisions[i]))
+	}
+	return http.StatusOK, response
+}
+
+// createCredential stores the first revision of a new credential. s.mu must be held.
+func (s *Server) createCredential(request confidant.CredentialRequestBody, from string) confidant.Credential {
+	credential := confidant.Credential{
+		ID:              newID(),
+		Name:            request.Name,
+		CredentialPairs: request.CredentialPairs,
+		Metadata:        request.Metadata,
+		Documentation:   request.Documentation,
+		Enabled:         request.Enabled,
+	}
+	return s.addRevision(credential, from)
+}
+
+// addRevision stores credential as the next revision of its ID. s.mu must be held.
+func (s *Server) addRevision(credential confidant.Credential, from string) confidant.Credential {
+	credential = copyCredential(credential)
+	credential.Revision = len(s.credentials[credential.ID]) + 1
+	credential.ModifiedBy = from
+	credential.ModifiedDate = now()
+	s.credentials[credential.ID] = append(s.credentials[credential.ID], credential)
+	return copyCredential(credential)
+}
+
+func (s *Server) credentialIDs() []string {
+	ids := make([]string, 0, len(s.credentials))
+	for id := range s.credentials {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (s *Server) routeBlindCredentials(method string, path []string, body []byte, from string) (int, interface{}) {
+	switch {
+	case method == "GET" && len(path) == 0:
+		response := confidant.BlindCredentialResponse{BlindCredentials: []confidant.BlindCredential{}}
+		ids := make([]string, 0, len(s.blindCredentials))
+		for id := range s.blindCredentials {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			response.BlindCredentials = append(response.BlindCredentials, *s.blindCredentials[id])
+		}
+		return http.StatusOK, response
+	case method == "GET" && len(path) == 1:
+		credential, ok := s.blindCredentials[path[0]]
+		if !ok {
+			return http.StatusNotFound, notFound
+		}
+		return http.StatusOK, *credential
+	case (method == "POST" && len(path) == 0) || (method == "PUT" && len(path) == 1):
+		var credential *confidant.BlindCredential
+		if method == "PUT" {
+			var ok bool
+			if credential, ok = s.blindCredentials[path[0]]; !ok {
+				return http.StatusNotFound, notFound
+			}
+		}
+		var request confidant.BlindCredentialRequestBody
+		if err := json.Unmarshal(body, &request); err != nil {
+			return http.StatusBadRequest, errorResponse{Error: "Request body must be JSON."}
+		}
+		if message := validateCredential(request.Name, len(request.CredentialPairs)); message != "" {
+			return http.StatusBadRequest, errorResponse{Error: message}
+		}
+		if len(request.DataKey) == 0 || request.CipherType == "" || request.CipherVersion == 0 {
+			return http.StatusBadRequest, errorResponse{Error: "data_key, cipher_type and cipher_version are required fields."}
+		}
+		if credential == nil {
+			credential = &confidant.BlindCredential{ID: newID()}
+			s.blindCredentials[credential.ID] = credential
+		}
+		credential.Name = request.Name
+		credential.CredentialPairs = request.CredentialPairs
+		credential.CredentialKeys = request.CredentialKeys
+		credential.DataKey = request.DataKey
+		credential.CipherType = request.CipherType
+		credential.CipherVersion = request.CipherVersion
+		credential.Metadata = request.Metadata
+		credential.Documentation = request.Documentation
+		credential.Enabled = request.Enabled
+		credential.Revision++
+		credential.ModifiedBy = from
+		credential.ModifiedDate = now()
+		return http.StatusOK, *credential
+	}
+	return http.StatusMethodNotAllowed, errorResponse{Error: "Method not allowed."}
+}
+
+func (s *Server) routeGrants(method string, id string) (int, interface{}) {
+	if _, ok := s.services[id]; !ok {
+		return http.StatusBadRequest, confidant.GrantsResponse{Error: "id provided does not exist"}
+	}
+	switch method {
+	case "GET":
+		return http.StatusOK, confidant.GrantsResponse{Grants: s.grants[id]}
+	case "PUT":
+		grants := confidant.Grants{EncryptGrant: true, DecryptGrant: true}
+		s.grants[id] = grants
+		return http.StatusOK, confidant.GrantsResponse{Grants: grants}
+	}
+	return http.StatusMethodNotAllowed, errorResponse{Error: "Method not allowed."}
+}
+
+func validateCredential(name string, pairs int) string {
+	if name == "" {
+		return "name is a required field."
+	}
+	if pairs == 0 {
+		return "credential_pairs is a required field."
+	}
+	return ""
+}
+
+// sameCredential reports whether reverting current to old would change nothing.
+func sameCredential(old, current confidant.Credential) bool {
+	old.Revision, old.ModifiedBy, old.ModifiedDate = 0, "", ""
+	current.Revision, current.ModifiedBy, current.ModifiedDate = 0, "", ""
+	a, _ := json.Marshal(old)
+	b, _ := json.Marshal(current)
+	return string(a) == string(b)
+}
+
+func copyCredential(credential confidant.Credential) confidant.Credential {
+	credential.CredentialPairs = copyMap(credential.CredentialPairs)
+	credential.Metadata = copyMap(credential.Metadata)
+	return credential
+}
+
+func copyMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	copied := make(map[string]string, len(m))
+	for k, v := range m {
+		copied[k] = v
+	}
+	return copied
+}
+
+// newID returns a random ID, formatted like Confidant's.
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func now() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
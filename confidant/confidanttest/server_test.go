@@ -0,0 +1,221 @@
+package confidanttest
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stripe/go-confidant-client/confidant"
+	"github.com/stripe/go-confidant-client/kmsauth/kmstest"
+)
+
+func TestServices(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := server.Client()
+	server.AddRoles("web")
+	server.AddCredential("db", map[string]string{"db_password": "hunter2"})
+	server.AddCredential("api", map[string]string{"api_key": "abc"})
+
+	service, err := client.CreateService("web", []string{"db"})
+	if err != nil {
+		t.Fatalf("Could not create service: %s", err)
+	}
+	if service.Revision != 1 || !service.Enabled || len(service.Credentials) != 1 {
+		t.Errorf("Expected an enabled service with one credential, got %+v", service)
+	}
+	if grants := server.Grants("web"); !grants.EncryptGrant || !grants.DecryptGrant {
+		t.Errorf("Expected the service's grants to be ensured, got %+v", grants)
+	}
+	if _, err := client.CreateService("web", nil); err != confidant.ErrServiceExists {
+		t.Errorf("Expected ErrServiceExists, got %v", err)
+	}
+	if _, err := client.CreateService("missing-role", nil); err == nil {
+		t.Errorf("Expected an error for a service without an IAM role")
+	}
+
+	if _, err := client.UpdateServiceCredentials("web", []string{"api"}, nil); err != nil {
+		t.Fatalf("Could not update service: %s", err)
+	}
+	pairs, err := client.ServiceCredentials("web")
+	if err != nil {
+		t.Fatalf("Could not get service credentials: %s", err)
+	}
+	if pairs["db_password"] != "hunter2" || pairs["api_key"] != "abc" {
+		t.Errorf("Expected both credentials' pairs, got %v", pairs)
+	}
+
+	if _, err := client.DisableService("web"); err != nil {
+		t.Fatalf("Could not disable service: %s", err)
+	}
+	stored, _ := server.Service("web")
+	if stored.Enabled || len(stored.Credentials) != 0 || stored.Revision != 3 {
+		t.Errorf("Expected revision 3 to be disabled without credentials, got %+v", stored)
+	}
+	services, err := client.GetServices()
+	if err != nil || len(services.Services) != 1 || services.Services[0].ID != "web" {
+		t.Errorf("Expected to list the web service, got %+v and %v", services, err)
+	}
+	if _, err := client.GetService("missing"); !errors.Is(err, confidant.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestConflictingKeys(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	a := server.AddCredential("a", map[string]string{"key": "a"})
+	b := server.AddCredential("b", map[string]string{"key": "b"})
+	server.AddService("web", []string{a.ID}, nil)
+
+	_, err := server.Client().UpdateServiceCredentials("web", []string{"b"}, nil)
+	var apiErr *confidant.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || !strings.Contains(apiErr.Body, b.ID) {
+		t.Errorf("Expected a 400 listing the conflicting credentials, got %v", err)
+	}
+}
+
+func TestCredentialRevisions(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := server.Client()
+
+	credential, err := client.CreateCredential(&confidant.CredentialRequestBody{
+		Name:            "db",
+		CredentialPairs: map[string]string{"password": "one"},
+		Enabled:         true,
+	})
+	if err != nil {
+		t.Fatalf("Could not create credential: %s", err)
+	}
+	if credential.ModifiedBy != AuthFrom {
+		t.Errorf("Expected the credential to be modified by %s, got %s", AuthFrom, credential.ModifiedBy)
+	}
+	if _, err := client.UpdateCredential(credential.ID, &confidant.CredentialRequestBody{
+		Name:            "db",
+		CredentialPairs: map[string]string{"password": "two"},
+		Enabled:         true,
+	}); err != nil {
+		t.Fatalf("Could not update credential: %s", err)
+	}
+	revisions, err := client.ListCredentialRevisions(credential.ID)
+	if err != nil || len(revisions) != 2 || revisions[0].CredentialPairs["password"] != "one" {
+		t.Fatalf("Expected two revisions, oldest first, got %v and %v", revisions, err)
+	}
+
+	reverted, err := client.RevertCredential(credential.ID, 1)
+	if err != nil {
+		t.Fatalf("Could not revert credential: %s", err)
+	}
+	if reverted.Revision != 3 || reverted.CredentialPairs["password"] != "one" {
+		t.Errorf("Expected revision 3 with the first password, got %+v", reverted)
+	}
+	if _, err := client.RevertCredential(credential.ID, 3); err == nil {
+		t.Errorf("Expected an error reverting to the current revision")
+	}
+	if _, err := client.CreateCredential(&confidant.CredentialRequestBody{Name: "empty"}); err == nil {
+		t.Errorf("Expected an error creating a credential without pairs")
+	}
+	if _, err := client.GetCredential("missing"); !errors.Is(err, confidant.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestBlindCredentials(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := server.Client()
+	server.AddService("web", nil, nil)
+	body := &confidant.BlindCredentialRequestBody{
+		Name:            "blind",
+		CredentialPairs: map[string]string{"us-east-1": "encrypted"},
+		CredentialKeys:  []string{"key"},
+		DataKey:         map[string]string{"us-east-1": "data-key"},
+		CipherType:      "fernet",
+		CipherVersion:   2,
+		Enabled:         true,
+	}
+	created, err := client.CreateBlindCredential(body)
+	if err != nil {
+		t.Fatalf("Could not create blind credential: %s", err)
+	}
+	body.Documentation = "rotated"
+	updated, err := client.UpdateBlindCredential(created.ID, body)
+	if err != nil || updated.Revision != 2 || updated.Documentation != "rotated" {
+		t.Errorf("Expected revision 2 of the blind credential, got %+v and %v", updated, err)
+	}
+	if err := client.AssignBlindCredential("web", "blind"); err != nil {
+		t.Fatalf("Could not assign blind credential: %s", err)
+	}
+	service, _ := server.Service("web")
+	if len(service.BlindCredentials) != 1 || service.BlindCredentials[0].ID != created.ID {
+		t.Errorf("Expected the blind credential to be assigned, got %+v", service.BlindCredentials)
+	}
+}
+
+func TestAuth(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+
+	otherKMS := kmstest.New()
+	otherKMS.AddKey(AuthKey)
+	client := server.Client(confidant.WithKMSClient(otherKMS), confidant.WithRetryPolicy(nil))
+	_, err := client.GetServices()
+	var apiErr *confidant.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected a 401 for a token from another KMS, got %v", err)
+	}
+
+	resp, err := http.Get(server.URL + "/v1/services")
+	if err != nil {
+		t.Fatalf("Could not make request: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected a 401 without auth headers, got %d", resp.StatusCode)
+	}
+
+	server.Validator = nil
+	if _, err := client.GetServices(); err != nil {
+		t.Errorf("Expected any token to be accepted without a Validator, got %v", err)
+	}
+	if requests := server.Requests(); len(requests) != 3 || requests[0].Username != "2/user/"+AuthFrom {
+		t.Errorf("Expected 3 requests from %s, got %+v", AuthFrom, requests)
+	}
+}
+
+func TestFail(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := server.Client(confidant.WithRetryPolicy(nil))
+
+	server.Fail("GET", "/v1/services", Failure{StatusCode: http.StatusServiceUnavailable, Message: "Down for maintenance.", Times: 1})
+	_, err := client.GetServices()
+	var apiErr *confidant.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable || apiErr.Message != "Down for maintenance." {
+		t.Errorf("Expected the injected 503, got %v", err)
+	}
+	if _, err := client.GetServices(); err != nil {
+		t.Errorf("Expected the failure to be used up, got %v", err)
+	}
+
+	server.Fail("", "/v1/roles", Failure{Message: "Internal error."})
+	for i := 0; i < 2; i++ {
+		err := client.CheckRole("web")
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError {
+			t.Errorf("Expected the failure to repeat as a 500 until cleared, got %v", err)
+		}
+	}
+	server.ClearFailures()
+	server.AddRoles("web")
+	if err := client.CheckRole("web"); err != nil {
+		t.Errorf("Expected no error after clearing failures, got %v", err)
+	}
+
+	// Idempotent requests are retried through a failure by the default retry policy.
+	server.Fail("GET", "/v1/roles", Failure{StatusCode: http.StatusServiceUnavailable, Times: 1})
+	if err := server.Client().CheckRole("web"); err != nil {
+		t.Errorf("Expected the request to be retried, got %v", err)
+	}
+}
@@ -0,0 +1,241 @@
+package confidant
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/stripe/go-confidant-client/kmsauth"
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultConfigFiles are the config files LoadConfig reads when none are given,
+// in order. They are the same as the Python confidant-client's.
+var DefaultConfigFiles = []string{"~/.confidant", "/etc/confidant/config"}
+
+const (
+	// DefaultProfile is the profile LoadConfig reads when none is given.
+	DefaultProfile = "default"
+	// DefaultRegion is the AWS region used for KMS when none is configured.
+	DefaultRegion = "us-east-1"
+)
+
+// Config is a profile from a Python confidant-client compatible config file,
+// whose fields are named the same. Config files are YAML or JSON maps of profile names to Configs:
+//
+//	default:
+//	  url: https://confidant.example.com
+//	  auth_key: alias/authnz
+//	  auth_context:
+//	    from: username
+//	    to: ConfidantServer
+//	    user_type: user
+//	  region: us-east-1
+type Config struct {
+	URL         string            `yaml:"url" json:"url"`
+	AuthKey     string            `yaml:"auth_key" json:"auth_key"`
+	AuthContext ConfigAuthContext `yaml:"auth_context" json:"auth_context"`
+	Region      string            `yaml:"region" json:"region"`
+	// TokenLifetime is the lifetime of generated tokens in minutes.
+	// Zero means kmsauth.DefaultTokenLifetime.
+	TokenLifetime int `yaml:"token_lifetime" json:"token_lifetime"`
+	// TokenVersion is the kmsauth token version. Zero means kmsauth.DefaultTokenVersion.
+	TokenVersion int `yaml:"token_version" json:"token_version"`
+	// UnixProxy is the path of a unix socket to proxy requests through, if any.
+	UnixProxy string `yaml:"unix_proxy" json:"unix_proxy"`
+}
+
+// ConfigAuthContext is the kmsauth context of a Config.
+type ConfigAuthContext struct {
+	From     string `yaml:"from" json:"from"`
+	To       string `yaml:"to" json:"to"`
+	UserType string `yaml:"user_type" json:"user_type"`
+}
+
+// LoadConfig reads a profile from the first config file that has it, and
+// overrides it with the CONFIDANT_URL, CONFIDANT_AUTH_KEY, CONFIDANT_FROM, CONFIDANT_TO,
+// CONFIDANT_USER_TYPE, CONFIDANT_REGION, CONFIDANT_TOKEN_LIFETIME, CONFIDANT_TOKEN_VERSION
+// and CONFIDANT_UNIX_PROXY environment variables.
+// If profile is empty, CONFIDANT_PROFILE or DefaultProfile is read.
+// If no files are given, the file in CONFIDANT_CONFIG or DefaultConfigFiles are read.
+// Default config files that don't exist are skipped, and a missing default profile is empty,
+// so that a Config can be built from the environment alone.
+func LoadConfig(profile string, files ...string) (*Config, error) {
+	return loadConfig(profile, files, os.Getenv)
+}
+
+func loadConfig(profile string, files []string, getenv func(string) string) (*Config, error) {
+	explicitProfile := profile != "" || getenv("CONFIDANT_PROFILE") != ""
+	if profile == "" {
+		profile = getenv("CONFIDANT_PROFILE")
+	}
+	if profile == "" {
+		profile = DefaultProfile
+	}
+	explicitFiles := len(files) != 0 || getenv("CONFIDANT_CONFIG") != ""
+	if len(files) == 0 {
+		if file := getenv("CONFIDANT_CONFIG"); file != "" {
+			files = []string{file}
+		} else {
+			files = DefaultConfigFiles
+		}
+	}
+
+	var config *Config
+	for _, file := range files {
+		profiles, err := readConfigFile(file)
+		if os.IsNotExist(err) && !explicitFiles {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if c, ok := profiles[profile]; ok {
+			config = &c
+			break
+		}
+	}
+	if config == nil {
+		if explicitProfile {
+			return nil, fmt.Errorf("Profile %s not found in config files %v", profile, files)
+		}
+		config = &Config{}
+	}
+	if err := config.applyEnv(getenv); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// readConfigFile reads the profiles in a YAML or JSON config file.
+func readConfigFile(file string) (map[string]Config, error) {
+	data, err := ioutil.ReadFile(expandPath(file))
+	if err != nil {
+		return nil, err
+	}
+	var profiles map[string]Config
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		err = json.Unmarshal(data, &profiles)
+	} else {
+		err = yaml.Unmarshal(data, &profiles)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Could not parse config file %s: %w", file, err)
+	}
+	return profiles, nil
+}
+
+// expandPath expands environment variables and a leading ~ in a path.
+func expandPath(path string) string {
+	path = os.ExpandEnv(path)
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[1:])
+		}
+	}
+	return path
+}
+
+func (c *Config) applyEnv(getenv func(string) string) error {
+	stringSettings := map[string]*string{
+		"CONFIDANT_URL":        &c.URL,
+		"CONFIDANT_AUTH_KEY":   &c.AuthKey,
+		"CONFIDANT_FROM":       &c.AuthContext.From,
+		"CONFIDANT_TO":         &c.AuthContext.To,
+		"CONFIDANT_USER_TYPE":  &c.AuthContext.UserType,
+		"CONFIDANT_REGION":     &c.Region,
+		"CONFIDANT_UNIX_PROXY": &c.UnixProxy,
+	}
+	for name, field := range stringSettings {
+		if value := getenv(name); value != "" {
+			*field = value
+		}
+	}
+	intSettings := map[string]*int{
+		"CONFIDANT_TOKEN_LIFETIME": &c.TokenLifetime,
+		"CONFIDANT_TOKEN_VERSION":  &c.TokenVersion,
+	}
+	for name, field := range intSettings {
+		if value := getenv(name); value != "" {
+			i, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%s must be an integer, got %q", name, value)
+			}
+			*field = i
+		}
+	}
+	return nil
+}
+
+// Validate checks the Config has the settings a Client needs.
+func (c *Config) Validate() error {
+	missing := []string{}
+	if c.URL == "" {
+		missing = append(missing, "url")
+	}
+	if c.AuthKey == "" {
+		missing = append(missing, "auth_key")
+	}
+	if c.AuthContext.From == "" {
+		missing = append(missing, "auth_context.from")
+	}
+	if c.AuthContext.To == "" {
+		missing = append(missing, "auth_context.to")
+	}
+	if len(missing) != 0 {
+		return fmt.Errorf("Missing configuration: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// NewClientFromConfig returns a client configured by a profile of the config files
+// and environment variables, as read by LoadConfig, and by opts.
+func NewClientFromConfig(profile string, opts ...Option) (*Client, error) {
+	config, err := LoadConfig(profile)
+	if err != nil {
+		return nil, err
+	}
+	return NewClientWithConfig(config, opts...)
+}
+
+// NewClientWithConfig returns a client configured by config and opts.
+// The user type defaults to "user" and the region to DefaultRegion.
+// The KMS options configure the token generator. Options take precedence over config:
+// WithAuthenticator, WithTokenGenerator and WithAuth replace the token generator made
+// from its auth settings, and WithHTTPClient replaces the client that would proxy
+// requests through config.UnixProxy.
+func NewClientWithConfig(config *Config, opts ...Option) (*Client, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	o := newOptions(opts)
+	tokenGenerator := o.newTokenGenerator()
+	if o.authenticator == nil && tokenGenerator == nil {
+		userType := config.AuthContext.UserType
+		if userType == "" {
+			userType = "user"
+		}
+		region := config.Region
+		if region == "" {
+			region = DefaultRegion
+		}
+		generator := kmsauth.NewTokenGenerator(config.AuthKey, config.AuthContext.To, config.AuthContext.From, userType, region, o.tokenGeneratorOptions(region)...)
+		if config.TokenLifetime != 0 {
+			generator.TokenLifetime = time.Duration(config.TokenLifetime) * time.Minute
+		}
+		if config.TokenVersion != 0 {
+			generator.TokenVersion = config.TokenVersion
+		}
+		tokenGenerator = &generator
+	}
+	if o.httpClient == nil && config.UnixProxy != "" {
+		o.httpClient = &http.Client{Transport: UnixProxy(config.UnixProxy)}
+	}
+	return o.newClient(config.URL, tokenGenerator), nil
+}
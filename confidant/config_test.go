@@ -0,0 +1,188 @@
+package confidant
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stripe/go-confidant-client/kmsauth"
+)
+
+func writeTestConfig(t *testing.T, dir string, name string, contents string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "confidant")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	user := writeTestConfig(t, dir, "user.yaml", `
+default:
+  url: https://confidant.example.com
+  auth_key: alias/authnz
+  auth_context:
+    from: username
+    to: ConfidantServer
+    user_type: user
+  region: us-west-2
+  token_lifetime: 10
+`)
+	system := writeTestConfig(t, dir, "system.json", `{
+	"default": {"url": "https://ignored.example.com"},
+	"staging": {
+		"url": "https://confidant-staging.example.com",
+		"auth_key": "alias/authnz-staging",
+		"auth_context": {"from": "username", "to": "ConfidantStaging"},
+		"unix_proxy": "/var/run/proxy.sock"
+	}
+}`)
+	files := []string{filepath.Join(dir, "missing"), user, system}
+
+	tests := []struct {
+		name     string
+		profile  string
+		files    []string
+		env      map[string]string
+		expected Config
+	}{
+		{
+			name:  "first file with the profile",
+			files: []string{user, system},
+			expected: Config{
+				URL:           "https://confidant.example.com",
+				AuthKey:       "alias/authnz",
+				AuthContext:   ConfigAuthContext{From: "username", To: "ConfidantServer", UserType: "user"},
+				Region:        "us-west-2",
+				TokenLifetime: 10,
+			},
+		},
+		{
+			name:    "named profile",
+			profile: "staging",
+			files:   []string{user, system},
+			expected: Config{
+				URL:         "https://confidant-staging.example.com",
+				AuthKey:     "alias/authnz-staging",
+				AuthContext: ConfigAuthContext{From: "username", To: "ConfidantStaging"},
+				UnixProxy:   "/var/run/proxy.sock",
+			},
+		},
+		{
+			name: "environment",
+			env: map[string]string{
+				"CONFIDANT_CONFIG":        system,
+				"CONFIDANT_PROFILE":       "staging",
+				"CONFIDANT_FROM":          "someone-else",
+				"CONFIDANT_REGION":        "eu-west-1",
+				"CONFIDANT_TOKEN_VERSION": "3",
+			},
+			expected: Config{
+				URL:          "https://confidant-staging.example.com",
+				AuthKey:      "alias/authnz-staging",
+				AuthContext:  ConfigAuthContext{From: "someone-else", To: "ConfidantStaging"},
+				Region:       "eu-west-1",
+				TokenVersion: 3,
+				UnixProxy:    "/var/run/proxy.sock",
+			},
+		},
+	}
+	for _, test := range tests {
+		config, err := loadConfig(test.profile, test.files, func(key string) string { return test.env[key] })
+		if err != nil {
+			t.Fatalf("%s: could not load config: %s", test.name, err)
+		}
+		if *config != test.expected {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.expected, *config)
+		}
+	}
+
+	if _, err := loadConfig("", files, func(string) string { return "" }); !os.IsNotExist(err) {
+		t.Errorf("Expected an error for a missing config file that was given explicitly, got %v", err)
+	}
+	if _, err := loadConfig("missing", []string{user}, func(string) string { return "" }); err == nil {
+		t.Errorf("Expected an error for a missing profile")
+	}
+	if _, err := loadConfig("", nil, func(key string) string {
+		return map[string]string{"CONFIDANT_TOKEN_LIFETIME": "ten"}[key]
+	}); err == nil {
+		t.Errorf("Expected an error for a token lifetime that isn't an integer")
+	}
+}
+
+func TestNewClientWithConfig(t *testing.T) {
+	config := &Config{
+		URL:           "https://confidant.example.com",
+		AuthKey:       "alias/authnz",
+		AuthContext:   ConfigAuthContext{From: "username", To: "ConfidantServer"},
+		TokenLifetime: 10,
+		TokenVersion:  3,
+		UnixProxy:     "/var/run/proxy.sock",
+	}
+	client, err := NewClientWithConfig(config)
+	if err != nil {
+		t.Fatalf("Could not create client: %s", err)
+	}
+	if client.url != config.URL {
+		t.Errorf("Expected url %s, got %s", config.URL, client.url)
+	}
+	if username := client.TokenGenerator.GetUsername(); username != "3/user/username" {
+		t.Errorf("Expected username 3/user/username, got %s", username)
+	}
+	if client.TokenGenerator.TokenLifetime != 10*time.Minute {
+		t.Errorf("Expected a token lifetime of 10m, got %s", client.TokenGenerator.TokenLifetime)
+	}
+	if _, ok := client.HttpClient.Transport.(*Transport); !ok {
+		t.Errorf("Expected a unix proxy transport, got %T", client.HttpClient.Transport)
+	}
+
+	_, err = NewClientWithConfig(&Config{URL: config.URL})
+	if err == nil || !strings.Contains(err.Error(), "auth_key, auth_context.from, auth_context.to") {
+		t.Errorf("Expected the missing settings to be listed, got %v", err)
+	}
+}
+
+func TestNewClientWithConfigOptionsTakePrecedence(t *testing.T) {
+	config := &Config{
+		URL:           "https://confidant.example.com",
+		AuthKey:       "alias/authnz",
+		AuthContext:   ConfigAuthContext{From: "username", To: "ConfidantServer"},
+		TokenLifetime: 10,
+	}
+	generator := kmsauth.NewTokenGenerator("alias/other", "ConfidantServer", "service-name", "service", "us-west-2")
+	client, err := NewClientWithConfig(config, WithTokenGenerator(&generator))
+	if err != nil {
+		t.Fatalf("Could not create client: %s", err)
+	}
+	if client.TokenGenerator != &generator {
+		t.Errorf("Expected the token generator given by WithTokenGenerator, got %+v", client.TokenGenerator)
+	}
+	if generator.TokenLifetime == 10*time.Minute {
+		t.Errorf("Expected the config not to change the given token generator")
+	}
+
+	client, err = NewClientWithConfig(config, WithAuth("alias/other", "ConfidantServer", "service-name", "service", "us-west-2"))
+	if err != nil {
+		t.Fatalf("Could not create client: %s", err)
+	}
+	if username := client.TokenGenerator.GetUsername(); username != "2/service/service-name" {
+		t.Errorf("Expected the username from WithAuth, got %s", username)
+	}
+
+	authenticator := &HeaderAuthenticator{}
+	client, err = NewClientWithConfig(config, WithAuthenticator(authenticator))
+	if err != nil {
+		t.Fatalf("Could not create client: %s", err)
+	}
+	if client.Authenticator != authenticator || client.TokenGenerator != nil {
+		t.Errorf("Expected only the authenticator given by WithAuthenticator, got %+v and %+v", client.Authenticator, client.TokenGenerator)
+	}
+}
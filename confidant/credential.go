@@ -1,6 +1,10 @@
 package confidant
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	"sort"
+)
 
 type Credential struct {
 	CredentialPairs map[string]string `json:"credential_pairs"`
@@ -8,6 +12,21 @@ type Credential struct {
 	ID              string            `json:"id"`
 	Name            string            `json:"name"`
 	Revision        int               `json:"revision"`
+	Metadata        map[string]string `json:"metadata"`
+	Documentation   string            `json:"documentation"`
+	ModifiedBy      string            `json:"modified_by"`
+	ModifiedDate    string            `json:"modified_date"`
+}
+
+// CredentialRequestBody is the body of requests to create or update a credential.
+// Updates replace the credential's name, pairs, metadata, documentation and enabled state,
+// so to change one of them, start from the credential's current values.
+type CredentialRequestBody struct {
+	Name            string            `json:"name"`
+	CredentialPairs map[string]string `json:"credential_pairs"`
+	Metadata        map[string]string `json:"metadata"`
+	Documentation   string            `json:"documentation"`
+	Enabled         bool              `json:"enabled"`
 }
 
 type CredentialResponse struct {
@@ -16,13 +35,23 @@ type CredentialResponse struct {
 	Credentials []Credential `json:"credentials"`
 }
 
+// CredentialRevisionsResponse is the response to a request for a credential's revisions.
+type CredentialRevisionsResponse struct {
+	Revisions []Credential `json:"revisions"`
+}
+
 // FindCredentialsByName returns a list of credentials for the names provided.
 // It fetches all credentials with a GET request to /v1/credentials
 // and filters them with the provided names.
-// If any credentials are missing, returns an error containing their names instead.
+// If any credentials are missing, returns a *CredentialsNotFoundError containing their names instead.
 func (c *Client) FindCredentialsByName(names []string) ([]*Credential, error) {
+	return c.FindCredentialsByNameWithContext(context.Background(), names)
+}
+
+// FindCredentialsByNameWithContext is like FindCredentialsByName, but takes a context.
+func (c *Client) FindCredentialsByNameWithContext(ctx context.Context, names []string) ([]*Credential, error) {
 	var response CredentialResponse
-	err := c.Request("GET", "/v1/credentials", nil, &response)
+	err := c.RequestWithContext(ctx, "GET", "/v1/credentials", nil, &response)
 	if err != nil {
 		return nil, err
 	}
@@ -41,7 +70,7 @@ func (c *Client) FindCredentialsByName(names []string) ([]*Credential, error) {
 		}
 	}
 	if len(missing) != 0 {
-		return nil, fmt.Errorf("The following credentials do not exist: %+v", missing)
+		return nil, &CredentialsNotFoundError{Names: missing}
 	}
 	return credentials, nil
 }
@@ -84,14 +113,115 @@ func createCredentialMap(init []*Credential, add []*Credential, remove []*Creden
 
 // AssignCredential assigns a credential to a service
 func (c *Client) AssignCredential(serviceName, credentialName string) error {
+	return c.AssignCredentialWithContext(context.Background(), serviceName, credentialName)
+}
+
+// AssignCredentialWithContext is like AssignCredential, but takes a context.
+func (c *Client) AssignCredentialWithContext(ctx context.Context, serviceName, credentialName string) error {
 	credentials := []string{credentialName}
-	_, err := c.UpdateServiceCredentials(serviceName, credentials, nil)
+	_, err := c.UpdateServiceCredentialsWithContext(ctx, serviceName, credentials, nil)
 	return err
 }
 
 // UnassignCredential removes a credential from a service
 func (c *Client) UnassignCredential(serviceName, credentialName string) error {
+	return c.UnassignCredentialWithContext(context.Background(), serviceName, credentialName)
+}
+
+// UnassignCredentialWithContext is like UnassignCredential, but takes a context.
+func (c *Client) UnassignCredentialWithContext(ctx context.Context, serviceName, credentialName string) error {
 	credentials := []string{credentialName}
-	_, err := c.UpdateServiceCredentials(serviceName, nil, credentials)
+	_, err := c.UpdateServiceCredentialsWithContext(ctx, serviceName, nil, credentials)
 	return err
 }
+
+// GetCredential fetches a credential, including its credential pairs, by ID.
+// It makes a GET request to /v1/credentials/id.
+func (c *Client) GetCredential(id string) (*Credential, error) {
+	return c.GetCredentialWithContext(context.Background(), id)
+}
+
+// GetCredentialWithContext is like GetCredential, but takes a context.
+func (c *Client) GetCredentialWithContext(ctx context.Context, id string) (*Credential, error) {
+	var credential Credential
+	err := c.RequestWithContext(ctx, "GET", "/v1/credentials/"+id, nil, &credential)
+	if err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// CreateCredential creates a new credential.
+// It makes a POST request to /v1/credentials, and returns the created credential.
+func (c *Client) CreateCredential(body *CredentialRequestBody) (*Credential, error) {
+	return c.CreateCredentialWithContext(context.Background(), body)
+}
+
+// CreateCredentialWithContext is like CreateCredential, but takes a context.
+func (c *Client) CreateCredentialWithContext(ctx context.Context, body *CredentialRequestBody) (*Credential, error) {
+	var credential Credential
+	err := c.RequestWithContext(ctx, "POST", "/v1/credentials", body, &credential)
+	if err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// UpdateCredential creates a new revision of an existing credential.
+// It makes a PUT request to /v1/credentials/id, and returns the updated credential,
+// whose Revision is the new revision.
+func (c *Client) UpdateCredential(id string, body *CredentialRequestBody) (*Credential, error) {
+	return c.UpdateCredentialWithContext(context.Background(), id, body)
+}
+
+// UpdateCredentialWithContext is like UpdateCredential, but takes a context.
+func (c *Client) UpdateCredentialWithContext(ctx context.Context, id string, body *CredentialRequestBody) (*Credential, error) {
+	var credential Credential
+	err := c.RequestWithContext(ctx, "PUT", "/v1/credentials/"+id, body, &credential)
+	if err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// ListCredentialRevisions returns every revision of a credential, oldest first.
+// Each revision has its credential pairs, ModifiedBy and ModifiedDate.
+// It makes a GET request to /v1/archive/credentials/id.
+func (c *Client) ListCredentialRevisions(id string) ([]*Credential, error) {
+	return c.ListCredentialRevisionsWithContext(context.Background(), id)
+}
+
+// ListCredentialRevisionsWithContext is like ListCredentialRevisions, but takes a context.
+func (c *Client) ListCredentialRevisionsWithContext(ctx context.Context, id string) ([]*Credential, error) {
+	var response CredentialRevisionsResponse
+	err := c.RequestWithContext(ctx, "GET", "/v1/archive/credentials/"+id, nil, &response)
+	if err != nil {
+		return nil, err
+	}
+	revisions := make([]*Credential, len(response.Revisions))
+	for i := range response.Revisions {
+		revisions[i] = &response.Revisions[i]
+	}
+	sort.SliceStable(revisions, func(i, j int) bool {
+		return revisions[i].Revision < revisions[j].Revision
+	})
+	return revisions, nil
+}
+
+// RevertCredential reverts a credential to one of its earlier revisions.
+// Confidant saves the reverted credential as a new revision, which is returned.
+// It makes a PUT request to /v1/credentials/id/revision.
+func (c *Client) RevertCredential(id string, revision int) (*Credential, error) {
+	return c.RevertCredentialWithContext(context.Background(), id, revision)
+}
+
+// RevertCredentialWithContext is like RevertCredential, but takes a context.
+func (c *Client) RevertCredentialWithContext(ctx context.Context, id string, revision int) (*Credential, error) {
+	var credential Credential
+	path := fmt.Sprintf("/v1/credentials/%s/%d", id, revision)
+	err := c.RequestWithContext(ctx, "PUT", path, nil, &credential)
+	if err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
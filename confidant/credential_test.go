@@ -1,6 +1,11 @@
 package confidant
 
 import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"testing"
 )
@@ -37,6 +42,9 @@ func TestFindCredentialsByName(t *testing.T) {
 	if err == nil || err.Error() != "The following credentials do not exist: [non-existant]" {
 		t.Errorf("Expected error (The following credentials do not exist: [non-existant]), got %e", err)
 	}
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected error to match ErrNotFound, got %v", err)
+	}
 }
 
 func TestGetCredentialIDs(t *testing.T) {
@@ -87,7 +95,7 @@ func TestAssignCredential(t *testing.T) {
 	}
 	expectedService := Service{
 		Account:          "",
-		BlindCredentials: make([]*Credential, 0),
+		BlindCredentials: make([]*BlindCredential, 0),
 		Credentials:      []*Credential{&initialCredential, &newCredential},
 	}
 	grants := Grants{EncryptGrant: true, DecryptGrant: true}
@@ -103,7 +111,7 @@ func TestAssignCredential(t *testing.T) {
 	if err != nil {
 		t.Errorf("Could not create service: %e", err)
 	}
-	service := c.services[serviceName]
+	service, _ := c.cachedService(serviceName)
 	if len(service.Credentials) != len(expectedService.Credentials) {
 		t.Errorf("Incorrect number of credentials: expected %d, got %d", len(expectedService.Credentials), len(service.Credentials))
 	}
@@ -125,7 +133,7 @@ func TestUnassignCredential(t *testing.T) {
 	}
 	expectedService := Service{
 		Account:          "",
-		BlindCredentials: make([]*Credential, 0),
+		BlindCredentials: make([]*BlindCredential, 0),
 		Credentials:      []*Credential{},
 	}
 	grants := Grants{EncryptGrant: true, DecryptGrant: true}
@@ -141,7 +149,7 @@ func TestUnassignCredential(t *testing.T) {
 	if err != nil {
 		t.Errorf("Could not create service: %e", err)
 	}
-	service := c.services[serviceName]
+	service, _ := c.cachedService(serviceName)
 	if len(service.Credentials) != len(expectedService.Credentials) {
 		t.Errorf("Incorrect number of credentials: expected %d, got %d", len(expectedService.Credentials), len(service.Credentials))
 	}
@@ -149,3 +157,150 @@ func TestUnassignCredential(t *testing.T) {
 		t.Errorf("Credentials don't match: expected %v, got %v", expectedService.Credentials, service.Credentials)
 	}
 }
+
+func TestGetCredential(t *testing.T) {
+	expected := Credential{
+		ID:              "abc",
+		Name:            "name",
+		Revision:        3,
+		Enabled:         true,
+		CredentialPairs: map[string]string{"key": "value"},
+		Metadata:        map[string]string{"team": "cloud"},
+		Documentation:   "Rotate me",
+		ModifiedBy:      "username",
+		ModifiedDate:    "Wed, 25 Jul 2018 01:26:17 GMT",
+	}
+	responses := map[string]interface{}{"GET/v1/credentials/abc": expected}
+	ts, c := CreateMockClientAndServer(responses, t)
+	defer ts.Close()
+	credential, err := c.GetCredential("abc")
+	if err != nil {
+		t.Fatalf("Could not get credential: %s", err)
+	}
+	if !reflect.DeepEqual(*credential, expected) {
+		t.Errorf("Expected %+v credential, got %+v", expected, *credential)
+	}
+}
+
+// credentialServer replies to requests with response, and records the body of the last request.
+func credentialServer(t *testing.T, method string, path string, response Credential, body *CredentialRequestBody) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method || r.URL.Path != path {
+			t.Errorf("Expected %s %s, got %s %s", method, path, r.Method, r.URL.Path)
+		}
+		bodyBytes, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("Unable to read request body: %s", err)
+		}
+		var raw map[string]interface{}
+		if err := json.Unmarshal(bodyBytes, &raw); err != nil {
+			t.Errorf("Unable to unmarshal body: %s", err)
+		}
+		for _, key := range []string{"credential_pairs", "metadata"} {
+			if _, ok := raw[key].(map[string]interface{}); !ok {
+				t.Errorf("Expected %s to be an object, got %v", key, raw[key])
+			}
+		}
+		json.Unmarshal(bodyBytes, body)
+		json.NewEncoder(w).Encode(response)
+	}))
+}
+
+func TestCreateCredential(t *testing.T) {
+	body := CredentialRequestBody{
+		Name:            "name",
+		CredentialPairs: map[string]string{"key": "value"},
+		Documentation:   "Rotate me",
+		Enabled:         true,
+	}
+	response := Credential{ID: "abc", Name: "name", Revision: 1, Enabled: true, CredentialPairs: body.CredentialPairs}
+	var received CredentialRequestBody
+	ts := credentialServer(t, "POST", "/v1/credentials", response, &received)
+	defer ts.Close()
+	c := createMockClient(ts.URL)
+	credential, err := c.CreateCredential(&body)
+	if err != nil {
+		t.Fatalf("Could not create credential: %s", err)
+	}
+	if !reflect.DeepEqual(received, body) {
+		t.Errorf("Expected request body %+v, got %+v", body, received)
+	}
+	if credential.ID != "abc" || credential.Revision != 1 {
+		t.Errorf("Expected credential abc at revision 1, got %+v", credential)
+	}
+}
+
+func TestUpdateCredential(t *testing.T) {
+	body := CredentialRequestBody{
+		Name:            "name",
+		CredentialPairs: map[string]string{"key": "new value"},
+		Metadata:        map[string]string{"team": "cloud"},
+		Enabled:         true,
+	}
+	response := Credential{ID: "abc", Name: "name", Revision: 2, Enabled: true, CredentialPairs: body.CredentialPairs}
+	var received CredentialRequestBody
+	ts := credentialServer(t, "PUT", "/v1/credentials/abc", response, &received)
+	defer ts.Close()
+	c := createMockClient(ts.URL)
+	credential, err := c.UpdateCredential("abc", &body)
+	if err != nil {
+		t.Fatalf("Could not update credential: %s", err)
+	}
+	if !reflect.DeepEqual(received, body) {
+		t.Errorf("Expected request body %+v, got %+v", body, received)
+	}
+	if credential.Revision != 2 {
+		t.Errorf("Expected revision 2, got %d", credential.Revision)
+	}
+}
+
+func TestListCredentialRevisions(t *testing.T) {
+	first := Credential{
+		ID:              "abc-1",
+		Name:            "name",
+		Revision:        1,
+		CredentialPairs: map[string]string{"key": "old value"},
+		ModifiedBy:      "alice",
+		ModifiedDate:    "Wed, 25 Jul 2018 01:26:17 GMT",
+	}
+	second := Credential{
+		ID:              "abc-2",
+		Name:            "name",
+		Revision:        2,
+		CredentialPairs: map[string]string{"key": "new value"},
+		ModifiedBy:      "bob",
+		ModifiedDate:    "Thu, 26 Jul 2018 01:26:17 GMT",
+	}
+	responses := map[string]interface{}{
+		"GET/v1/archive/credentials/abc": CredentialRevisionsResponse{Revisions: []Credential{second, first}},
+	}
+	ts, c := CreateMockClientAndServer(responses, t)
+	defer ts.Close()
+	revisions, err := c.ListCredentialRevisions("abc")
+	if err != nil {
+		t.Fatalf("Could not list credential revisions: %s", err)
+	}
+	expected := []*Credential{&first, &second}
+	if !reflect.DeepEqual(revisions, expected) {
+		t.Errorf("Expected revisions %+v, got %+v", expected, revisions)
+	}
+}
+
+func TestRevertCredential(t *testing.T) {
+	expected := Credential{
+		ID:              "abc",
+		Name:            "name",
+		Revision:        3,
+		CredentialPairs: map[string]string{"key": "old value"},
+	}
+	responses := map[string]interface{}{"PUT/v1/credentials/abc/1": expected}
+	ts, c := CreateMockClientAndServer(responses, t)
+	defer ts.Close()
+	credential, err := c.RevertCredential("abc", 1)
+	if err != nil {
+		t.Fatalf("Could not revert credential: %s", err)
+	}
+	if !reflect.DeepEqual(*credential, expected) {
+		t.Errorf("Expected %+v credential, got %+v", expected, *credential)
+	}
+}
@@ -0,0 +1,97 @@
+package confidant
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrNotFound is matched by errors for services, credentials or other resources that do not exist.
+	ErrNotFound = errors.New("Not Found")
+	// ErrForbidden is matched by errors for requests Confidant did not authorize.
+	ErrForbidden = errors.New("Forbidden")
+	// ErrServiceExists is returned by CreateService when the service already exists.
+	ErrServiceExists = errors.New("Service Already Exists")
+	// ErrInvalidRole is returned by CheckRole when the service name is not an IAM role.
+	ErrInvalidRole = errors.New("Invalid IAM Role")
+	// ErrConflictingKeys is matched by errors for credentials of a service that share keys.
+	ErrConflictingKeys = errors.New("Conflicting Credential Keys")
+)
+
+// APIError is returned when Confidant responds with an unexpected status code,
+// or with an error in the response body.
+// It matches ErrNotFound and ErrForbidden with errors.Is, based on its status code.
+type APIError struct {
+	StatusCode int
+	Method     string
+	Path       string
+	// Message is the "error" field of the response body, if any.
+	Message string
+	// Body is the raw response body.
+	Body string
+}
+
+func (e *APIError) Error() string {
+	message := e.Message
+	if message == "" {
+		message = e.Body
+	}
+	return fmt.Sprintf("Confidant Request %s %s Failed: got status code %d with error %s", e.Method, e.Path, e.StatusCode, message)
+}
+
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	}
+	return false
+}
+
+// CredentialsNotFoundError is returned when credentials looked up by name do not exist.
+// It matches ErrNotFound with errors.Is.
+type CredentialsNotFoundError struct {
+	Names []string
+}
+
+func (e *CredentialsNotFoundError) Error() string {
+	return fmt.Sprintf("The following credentials do not exist: %+v", e.Names)
+}
+
+func (e *CredentialsNotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// ConflictingKeysError is returned by ServiceCredentials when more than one of a service's
+// credentials has the same key. Conflicts maps each such key to the names of its credentials.
+// It matches ErrConflictingKeys with errors.Is.
+type ConflictingKeysError struct {
+	Service   string
+	Conflicts map[string][]string
+}
+
+func (e *ConflictingKeysError) Error() string {
+	keys := make([]string, 0, len(e.Conflicts))
+	for key := range e.Conflicts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	conflicts := make([]string, len(keys))
+	for i, key := range keys {
+		conflicts[i] = fmt.Sprintf("%s in %v", key, e.Conflicts[key])
+	}
+	return fmt.Sprintf("Credentials of service %s have conflicting keys: %s", e.Service, strings.Join(conflicts, ", "))
+}
+
+func (e *ConflictingKeysError) Is(target error) bool {
+	return target == ErrConflictingKeys
+}
+
+// bodyError returns an *APIError for an error in the body of a 200 response.
+func bodyError(method, path, message string) error {
+	return &APIError{StatusCode: http.StatusOK, Method: method, Path: path, Message: message}
+}
@@ -1,8 +1,17 @@
 package confidant
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/stripe/go-confidant-client/kmsauth/kmsv1"
 )
 
 func ExampleRequest() {
@@ -34,6 +43,30 @@ func ExampleGetService() {
 	fmt.Printf("%+v\n", service)
 }
 
+func ExampleClient_GetServiceWithContext() {
+	name := "name"
+	c := initClient()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	service, err := c.GetServiceWithContext(ctx, name)
+	if err != nil {
+		log.Printf("Got an error when getting the service named %s: %e", name, err)
+	}
+	fmt.Printf("%+v\n", service)
+}
+
+func ExampleAPIError() {
+	name := "name"
+	c := initClient()
+	_, err := c.GetService(name)
+	var apiErr *APIError
+	if errors.Is(err, ErrNotFound) {
+		log.Printf("The service named %s doesn't exist", name)
+	} else if errors.As(err, &apiErr) {
+		log.Printf("Confidant responded with %d: %s", apiErr.StatusCode, apiErr.Message)
+	}
+}
+
 func ExampleCreateService() {
 	name := "test-go-confidant-create-service"
 	c := initClient()
@@ -125,7 +158,7 @@ func ExampleAssignCredential() {
 	if err != nil {
 		log.Printf("Got an error when assigning %s to %s", credential, service)
 	}
-	fmt.Println(c.services)
+	fmt.Println(c.GetService(service))
 }
 
 func ExampleUnassignCredential() {
@@ -136,5 +169,163 @@ func ExampleUnassignCredential() {
 	if err != nil {
 		log.Printf("Got an error when unassigning %s to %s", credential, service)
 	}
-	fmt.Println(c.services)
+	fmt.Println(c.GetService(service))
+}
+
+func ExampleClient_GetCredential() {
+	id := "credential-id"
+	c := initClient()
+	credential, err := c.GetCredential(id)
+	if err != nil {
+		log.Printf("Got an error when getting the credential %s: %e", id, err)
+	}
+	fmt.Printf("%+v", credential)
+}
+
+func ExampleClient_CreateCredential() {
+	c := initClient()
+	credential, err := c.CreateCredential(&CredentialRequestBody{
+		Name:            "test-credential",
+		CredentialPairs: map[string]string{"api_key": "secret"},
+		Metadata:        map[string]string{"team": "cloud"},
+		Documentation:   "How to rotate this credential",
+		Enabled:         true,
+	})
+	if err != nil {
+		log.Printf("Got an error when creating a credential: %e", err)
+	}
+	fmt.Printf("%+v", credential)
+}
+
+func ExampleClient_UpdateCredential() {
+	id := "credential-id"
+	c := initClient()
+	credential, err := c.GetCredential(id)
+	if err != nil {
+		log.Printf("Got an error when getting the credential %s: %e", id, err)
+		return
+	}
+	credential.CredentialPairs["api_key"] = "new-secret"
+	credential, err = c.UpdateCredential(id, &CredentialRequestBody{
+		Name:            credential.Name,
+		CredentialPairs: credential.CredentialPairs,
+		Metadata:        credential.Metadata,
+		Documentation:   credential.Documentation,
+		Enabled:         credential.Enabled,
+	})
+	if err != nil {
+		log.Printf("Got an error when updating the credential %s: %e", id, err)
+	}
+	fmt.Println(credential.Revision)
+}
+
+func ExampleClient_ListCredentialRevisions() {
+	id := "credential-id"
+	c := initClient()
+	revisions, err := c.ListCredentialRevisions(id)
+	if err != nil {
+		log.Printf("Got an error when listing the revisions of credential %s: %e", id, err)
+	}
+	for _, revision := range revisions {
+		fmt.Println(revision.Revision, revision.ModifiedBy, revision.ModifiedDate)
+	}
+}
+
+func ExampleClient_RevertCredential() {
+	id := "credential-id"
+	c := initClient()
+	credential, err := c.RevertCredential(id, 1)
+	if err != nil {
+		log.Printf("Got an error when reverting the credential %s: %e", id, err)
+	}
+	fmt.Println(credential.Revision)
+}
+
+func ExampleClient_AssignBlindCredential() {
+	service := "service-name"
+	credential := "blind-credential-name"
+	c := initClient()
+	err := c.AssignBlindCredential(service, credential)
+	if err != nil {
+		log.Printf("Got an error when assigning the blind credential %s to service %s: %e", credential, service, err)
+	}
+	fmt.Println(c.GetService(service))
+}
+
+func ExampleEncryptBlindCredentialPairs() {
+	c := initClient()
+	encryptionContext := map[string]*string{"group": aws.String("web")}
+	keys := []BlindKey{
+		{Region: "us-east-1", KeyID: "alias/confidant-blind", KMSClient: kms.New(session.New(), &aws.Config{Region: aws.String("us-east-1")})},
+		{Region: "us-west-2", KeyID: "alias/confidant-blind", KMSClient: kms.New(session.New(), &aws.Config{Region: aws.String("us-west-2")})},
+	}
+	body, err := EncryptBlindCredentialPairs(keys, encryptionContext, map[string]string{"api_key": "secret"})
+	if err != nil {
+		log.Printf("Got an error when encrypting the credential pairs: %e", err)
+		return
+	}
+	body.Name = "blind-credential-name"
+	body.Enabled = true
+	credential, err := c.CreateBlindCredential(body)
+	if err != nil {
+		log.Printf("Got an error when creating the blind credential: %e", err)
+		return
+	}
+	pairs, err := DecryptBlindCredentialPairs(credential, "us-east-1", kmsv1.New(keys[0].KMSClient), encryptionContext)
+	if err != nil {
+		log.Printf("Got an error when decrypting the blind credential: %e", err)
+	}
+	fmt.Println(pairs)
+}
+
+func ExampleClient_ServiceCredentials() {
+	name := "service-name"
+	c := initClient()
+	pairs, err := c.ServiceCredentials(name)
+	var conflictErr *ConflictingKeysError
+	if errors.As(err, &conflictErr) {
+		log.Printf("Credentials of %s share keys: %v", name, conflictErr.Conflicts)
+	} else if err != nil {
+		log.Printf("Got an error when getting the credentials of service %s: %e", name, err)
+	}
+	fmt.Println(pairs["api_key"])
+}
+
+func ExampleNewClientFromConfig() {
+	c, err := NewClientFromConfig("staging")
+	if err != nil {
+		log.Printf("Got an error when creating a client: %e", err)
+		return
+	}
+	fmt.Println(c.GetServices())
+}
+
+func ExampleNew() {
+	sess := session.Must(session.NewSession())
+	c, err := New("https://confidant.example.com",
+		WithAuth("alias/authnz", "ConfidantServer", "username", "user", "us-east-1"),
+		WithAWSSession(sess),
+		WithUserAgent("my-service/1.0"),
+		WithServiceCacheTTL(time.Minute),
+	)
+	if err != nil {
+		log.Printf("Got an error when creating a client: %e", err)
+		return
+	}
+	fmt.Println(c.GetServices())
+}
+
+func ExampleSessionAuthenticator() {
+	c, err := New("https://confidant.example.com",
+		WithAuthenticator(&SessionAuthenticator{
+			Cookies: []*http.Cookie{{Name: "session", Value: "session-cookie-value"}},
+		}),
+	)
+	if err != nil {
+		log.Printf("Got an error when creating a client: %e", err)
+		return
+	}
+	// The first GET request sets the XSRF token needed by requests that change anything.
+	fmt.Println(c.GetServices())
+	fmt.Println(c.EnableService("service-name"))
 }
@@ -1,9 +1,9 @@
 package confidant
 
 import (
+	"context"
 	"fmt"
 	"log"
-	"time"
 )
 
 type Grants struct {
@@ -19,31 +19,53 @@ type GrantsResponse struct {
 // GetGrants fetches a service's grants.
 // It makes a GET request to /v1/grants/serviceName.
 func (c *Client) GetGrants(serviceName string) (*Grants, error) {
+	return c.GetGrantsWithContext(context.Background(), serviceName)
+}
+
+// GetGrantsWithContext is like GetGrants, but takes a context.
+func (c *Client) GetGrantsWithContext(ctx context.Context, serviceName string) (*Grants, error) {
 	var response GrantsResponse
-	err := c.Request("GET", "/v1/grants/"+serviceName, nil, &response)
+	err := c.RequestWithContext(ctx, "GET", "/v1/grants/"+serviceName, nil, &response)
 	return &response.Grants, err
 }
 
 // EnsureGrants adds encrypt and decrypt grants for a service.
-// It makes 10 PUT requests to /v1/grants/serviceName.
+// It makes PUT requests to /v1/grants/serviceName until both grants exist,
+// waiting between requests according to the client's RetryPolicy.
 // If the error from Confidant indicates that repeated requests will not succeed,
-// it returns an error immediately.
-// If 10 requests fail an error is returned.
+// it returns an error wrapping the *APIError immediately.
+// If the retry policy gives up an error is returned.
+// Use EnsureGrantsWithContext to stop waiting between requests early.
 func (c *Client) EnsureGrants(serviceName string) error {
-	var response GrantsResponse
+	return c.EnsureGrantsWithContext(context.Background(), serviceName)
+}
+
+// EnsureGrantsWithContext is like EnsureGrants, but takes a context.
+func (c *Client) EnsureGrantsWithContext(ctx context.Context, serviceName string) error {
+	path := "/v1/grants/" + serviceName
 	doesNotExist := "id provided does not exist"
 	isNotAService := "id provided is not a service"
-	for i := 0; i < 10; i++ {
-		err := c.Request("PUT", "/v1/grants/"+serviceName, nil, &response)
-		if err == nil {
-			if response.Error == doesNotExist || response.Error == isNotAService {
-				return fmt.Errorf("Failed to create KMS grant for %s, got %s", serviceName, response.Error)
-			} else if response.Grants.DecryptGrant && response.Grants.EncryptGrant {
-				return nil
-			}
+	for attempt := 1; ; attempt++ {
+		var response GrantsResponse
+		err := c.RequestWithContext(ctx, "PUT", path, nil, &response)
+		if err != nil {
+			return fmt.Errorf("Failed to create KMS grants for %s: %w", serviceName, err)
+		} else if response.Error == doesNotExist || response.Error == isNotAService {
+			return fmt.Errorf("Failed to create KMS grant for %s: %w", serviceName, bodyError("PUT", path, response.Error))
+		} else if response.Grants.DecryptGrant && response.Grants.EncryptGrant {
+			return nil
+		}
+		if c.RetryPolicy == nil {
+			break
+		}
+		delay, ok := c.RetryPolicy.Backoff(attempt, nil)
+		if !ok {
+			break
+		}
+		log.Printf("Failed to create KMS grants for %s, trying again in %s (%s)", serviceName, delay, response.Error)
+		if err := sleepContext(ctx, delay); err != nil {
+			return err
 		}
-		log.Printf("Failed to create KMS grants for %s, trying again (err: %e, %s)", serviceName, err, response.Error)
-		time.Sleep(2 * time.Second)
 	}
 	return fmt.Errorf("Failed to create KMS grants for %s", serviceName)
 }
@@ -1,7 +1,12 @@
 package confidant
 
 import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
 	"testing"
+	"time"
 )
 
 func TestGetGrants(t *testing.T) {
@@ -46,3 +51,45 @@ func TestEnsureGrants(t *testing.T) {
 		t.Errorf("Could not ensure grants for service %s: %e", serviceName, err)
 	}
 }
+
+func TestEnsureGrantsWithContextCanceled(t *testing.T) {
+	serviceName := "service-name"
+	response := GrantsResponse{
+		Grants: Grants{EncryptGrant: true, DecryptGrant: false},
+	}
+	responses := map[string]interface{}{"PUT/v1/grants/" + serviceName: response}
+	ts, c := CreateMockClientAndServer(responses, t)
+	defer ts.Close()
+	// The mock KMS client only implements Encrypt, so send the token it would give directly.
+	c.Authenticator = &HeaderAuthenticator{Header: http.Header{
+		"X-Auth-From":  {"2/user/go-confidant-client"},
+		"X-Auth-Token": {base64.StdEncoding.EncodeToString([]byte("token"))},
+	}}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := c.EnsureGrantsWithContext(ctx, serviceName)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("EnsureGrantsWithContext took %s to return after its context was done", elapsed)
+	}
+}
+
+func TestEnsureGrantsServiceDoesNotExist(t *testing.T) {
+	serviceName := "service-name"
+	responses := map[string]interface{}{
+		"PUT/v1/grants/" + serviceName: mockResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       GrantsResponse{Error: "id provided does not exist"},
+		},
+	}
+	ts, c := CreateMockClientAndServer(responses, t)
+	defer ts.Close()
+	err := c.EnsureGrants(serviceName)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Message != "id provided does not exist" {
+		t.Errorf("Expected an *APIError for a service that does not exist, got %v", err)
+	}
+}
@@ -0,0 +1,193 @@
+package confidant
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	"github.com/stripe/go-confidant-client/kmsauth"
+	"github.com/stripe/go-confidant-client/kmsauth/kmsv1"
+)
+
+// Option configures a Client made by New, NewClientWithConfig or NewClientFromConfig.
+type Option func(*options)
+
+type options struct {
+	httpClient       *http.Client
+	tokenGenerator   *kmsauth.TokenGenerator
+	authenticator    Authenticator
+	auth             *authSettings
+	retryPolicy      RetryPolicy
+	retryPolicySet   bool
+	userAgent        string
+	serviceCacheTTL  *time.Duration
+	serviceCacheSize int
+	kmsOptions       []kmsauth.Option
+	kmsConfig        kmsv1.Config
+}
+
+// authSettings are the arguments of kmsauth.NewTokenGenerator given to WithAuth.
+type authSettings struct {
+	keyID, to, from, userType, region string
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithHTTPClient makes requests with httpClient instead of a new http.Client.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = httpClient
+	}
+}
+
+// WithTokenGenerator authenticates requests with tokens from tokenGenerator.
+// The KMS options are ignored when it is used.
+func WithTokenGenerator(tokenGenerator *kmsauth.TokenGenerator) Option {
+	return func(o *options) {
+		o.tokenGenerator = tokenGenerator
+	}
+}
+
+// WithAuthenticator authenticates requests with authenticator, such as a
+// SessionAuthenticator or HeaderAuthenticator, instead of kmsauth tokens.
+// The token generator options are ignored when it is used.
+func WithAuthenticator(authenticator Authenticator) Option {
+	return func(o *options) {
+		o.authenticator = authenticator
+	}
+}
+
+// WithAuth authenticates requests with a TokenGenerator made by kmsauth.NewTokenGenerator
+// with these arguments and the KMS options.
+func WithAuth(keyID, to, from, userType, region string) Option {
+	return func(o *options) {
+		o.auth = &authSettings{keyID: keyID, to: to, from: from, userType: userType, region: region}
+	}
+}
+
+// WithRetryPolicy sets the client's RetryPolicy. A nil policy disables retries.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(o *options) {
+		o.retryPolicy = policy
+		o.retryPolicySet = true
+	}
+}
+
+// WithUserAgent sends userAgent as the User-Agent of Confidant requests,
+// and appends it to the User-Agent of KMS requests.
+func WithUserAgent(userAgent string) Option {
+	return func(o *options) {
+		o.userAgent = userAgent
+		o.kmsConfig.UserAgent = userAgent
+	}
+}
+
+// WithServiceCacheTTL sets the client's ServiceCacheTTL.
+func WithServiceCacheTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.serviceCacheTTL = &ttl
+	}
+}
+
+// WithServiceCacheSize sets the client's ServiceCacheSize.
+func WithServiceCacheSize(size int) Option {
+	return func(o *options) {
+		o.serviceCacheSize = size
+	}
+}
+
+// WithKMSClient makes the token generator use the SDK v1 kmsClient, instead of creating one.
+func WithKMSClient(kmsClient kmsiface.KMSAPI) Option {
+	return func(o *options) {
+		o.kmsOptions = append(o.kmsOptions, kmsauth.WithKMSClient(kmsClient))
+	}
+}
+
+// WithEncrypter makes the token generator encrypt tokens with encrypter,
+// such as an SDK v2 client adapted by kmsv2.New, as kmsauth.WithEncrypter.
+func WithEncrypter(encrypter kmsauth.Encrypter) Option {
+	return func(o *options) {
+		o.kmsOptions = append(o.kmsOptions, kmsauth.WithEncrypter(encrypter))
+	}
+}
+
+// WithAWSSession makes the token generator's KMS client from session, instead of session.New().
+func WithAWSSession(session client.ConfigProvider) Option {
+	return func(o *options) {
+		o.kmsConfig.Session = session
+	}
+}
+
+// WithAWSConfig merges config into the token generator's KMS client configuration,
+// after its region. Use it to set an endpoint, credentials or retries for KMS.
+func WithAWSConfig(config *aws.Config) Option {
+	return func(o *options) {
+		o.kmsConfig.AWSConfigs = append(o.kmsConfig.AWSConfigs, config)
+	}
+}
+
+// New returns a client for the Confidant server at url, configured by opts.
+// WithAuthenticator, WithTokenGenerator or WithAuth is required.
+//
+//	client, err := confidant.New("https://confidant.example.com",
+//		confidant.WithAuth("alias/authnz", "ConfidantServer", "username", "user", "us-east-1"),
+//		confidant.WithUserAgent("my-service/1.0"),
+//	)
+func New(url string, opts ...Option) (*Client, error) {
+	o := newOptions(opts)
+	tokenGenerator := o.newTokenGenerator()
+	if o.authenticator == nil && tokenGenerator == nil {
+		return nil, errors.New("An authenticator is required, use WithAuthenticator, WithTokenGenerator or WithAuth")
+	}
+	return o.newClient(url, tokenGenerator), nil
+}
+
+// newTokenGenerator returns the token generator given by WithTokenGenerator, or one made for WithAuth.
+// It returns nil if WithAuthenticator is used or neither is.
+func (o *options) newTokenGenerator() *kmsauth.TokenGenerator {
+	if o.authenticator != nil || o.tokenGenerator != nil {
+		return o.tokenGenerator
+	}
+	if o.auth == nil {
+		return nil
+	}
+	generator := kmsauth.NewTokenGenerator(o.auth.keyID, o.auth.to, o.auth.from, o.auth.userType, o.auth.region, o.tokenGeneratorOptions(o.auth.region)...)
+	return &generator
+}
+
+// tokenGeneratorOptions returns the options for kmsauth.NewTokenGenerator. Unless WithKMSClient
+// or WithEncrypter is used, it creates an SDK v1 KMS client for region configured by the other KMS options.
+func (o *options) tokenGeneratorOptions(region string) []kmsauth.Option {
+	if len(o.kmsOptions) != 0 {
+		return o.kmsOptions
+	}
+	return []kmsauth.Option{kmsauth.WithKMSClient(o.kmsConfig.NewClient(region))}
+}
+
+// newClient returns a client with the options other than the authentication ones applied.
+func (o *options) newClient(url string, tokenGenerator *kmsauth.TokenGenerator) *Client {
+	httpClient := o.httpClient
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	client := NewClient(url, httpClient, tokenGenerator)
+	if o.retryPolicySet {
+		client.RetryPolicy = o.retryPolicy
+	}
+	if o.serviceCacheTTL != nil {
+		client.ServiceCacheTTL = *o.serviceCacheTTL
+	}
+	client.ServiceCacheSize = o.serviceCacheSize
+	client.UserAgent = o.userAgent
+	client.Authenticator = o.authenticator
+	return &client
+}
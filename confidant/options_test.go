@@ -0,0 +1,121 @@
+package confidant
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/stripe/go-confidant-client/kmsauth"
+)
+
+func TestNew(t *testing.T) {
+	var userAgent string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+		w.Write([]byte(`{"services": []}`))
+	}))
+	defer ts.Close()
+	httpClient := &http.Client{}
+	kmsClient := &mockKMSClient{}
+	client, err := New(ts.URL,
+		WithAuth("key", "confidant", "go-confidant-client", "service", "us-west-2"),
+		WithKMSClient(kmsClient),
+		WithHTTPClient(httpClient),
+		WithUserAgent("my-service/1.0"),
+		WithRetryPolicy(nil),
+		WithServiceCacheTTL(0),
+		WithServiceCacheSize(10),
+	)
+	if err != nil {
+		t.Fatalf("Could not create client: %s", err)
+	}
+	if client.HttpClient != httpClient {
+		t.Errorf("Expected the given http client")
+	}
+	if client.TokenGenerator.KMSClient != kmsClient {
+		t.Errorf("Expected the given KMS client, got %T", client.TokenGenerator.KMSClient)
+	}
+	if username := client.TokenGenerator.GetUsername(); username != "2/service/go-confidant-client" {
+		t.Errorf("Expected username 2/service/go-confidant-client, got %s", username)
+	}
+	if client.RetryPolicy != nil {
+		t.Errorf("Expected no retry policy, got %T", client.RetryPolicy)
+	}
+	if client.ServiceCacheTTL != 0 || client.ServiceCacheSize != 10 {
+		t.Errorf("Expected a service cache TTL of 0 and size of 10, got %s and %d", client.ServiceCacheTTL, client.ServiceCacheSize)
+	}
+	if _, err := client.GetServices(); err != nil {
+		t.Fatalf("Could not get services: %s", err)
+	}
+	if userAgent != "my-service/1.0" {
+		t.Errorf("Expected user agent my-service/1.0, got %q", userAgent)
+	}
+}
+
+func TestNewDefaults(t *testing.T) {
+	generator := kmsauth.NewTokenGenerator("key", "confidant", "go-confidant-client", "user", "us-east-1", kmsauth.WithKMSClient(&mockKMSClient{}))
+	client, err := New("https://confidant.example.com", WithTokenGenerator(&generator))
+	if err != nil {
+		t.Fatalf("Could not create client: %s", err)
+	}
+	if client.TokenGenerator != &generator {
+		t.Errorf("Expected the given token generator")
+	}
+	if client.HttpClient == nil || client.RetryPolicy == nil {
+		t.Errorf("Expected a default http client and retry policy")
+	}
+	if client.ServiceCacheTTL != DefaultServiceCacheTTL {
+		t.Errorf("Expected the default service cache TTL, got %s", client.ServiceCacheTTL)
+	}
+
+	if _, err := New("https://confidant.example.com"); err == nil {
+		t.Errorf("Expected an error without a token generator")
+	}
+}
+
+func TestNewClientWithConfigOptions(t *testing.T) {
+	config := &Config{
+		URL:         "https://confidant.example.com",
+		AuthKey:     "alias/authnz",
+		AuthContext: ConfigAuthContext{From: "username", To: "ConfidantServer"},
+		UnixProxy:   "/var/run/proxy.sock",
+	}
+	kmsClient := &mockKMSClient{}
+	httpClient := &http.Client{}
+	client, err := NewClientWithConfig(config, WithKMSClient(kmsClient), WithHTTPClient(httpClient), WithServiceCacheTTL(time.Minute))
+	if err != nil {
+		t.Fatalf("Could not create client: %s", err)
+	}
+	if client.TokenGenerator.KMSClient != kmsClient {
+		t.Errorf("Expected the given KMS client, got %T", client.TokenGenerator.KMSClient)
+	}
+	if client.HttpClient != httpClient {
+		t.Errorf("Expected the given http client")
+	}
+	if client.ServiceCacheTTL != time.Minute {
+		t.Errorf("Expected a service cache TTL of 1m, got %s", client.ServiceCacheTTL)
+	}
+}
+
+func TestNewKMSOptions(t *testing.T) {
+	client, err := New("https://confidant.example.com",
+		WithAuth("key", "confidant", "go-confidant-client", "user", "us-west-2"),
+		WithAWSConfig(&aws.Config{Endpoint: aws.String("https://kms.example.com")}),
+	)
+	if err != nil {
+		t.Fatalf("Could not create client: %s", err)
+	}
+	kmsClient, ok := client.TokenGenerator.KMSClient.(*kms.KMS)
+	if !ok {
+		t.Fatalf("Expected an SDK v1 KMS client, got %T", client.TokenGenerator.KMSClient)
+	}
+	if region := aws.StringValue(kmsClient.Config.Region); region != "us-west-2" {
+		t.Errorf("Expected a KMS client for us-west-2, got %s", region)
+	}
+	if endpoint := aws.StringValue(kmsClient.Config.Endpoint); endpoint != "https://kms.example.com" {
+		t.Errorf("Expected the configured KMS endpoint, got %s", endpoint)
+	}
+}
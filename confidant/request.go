@@ -2,8 +2,8 @@ package confidant
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"fmt"
 	"io/ioutil"
 	"net/http"
 )
@@ -15,53 +15,141 @@ type RequestBody struct {
 	Enabled          bool     `json:"enabled"`
 }
 
-func (c *Client) Request(method string, path string, body *RequestBody, result interface{}) error {
+// Request makes an authenticated request to the Confidant API
+// and unmarshals the JSON response into result.
+// body is marshaled to JSON, and is usually a *RequestBody, *CredentialRequestBody or *BlindCredentialRequestBody.
+func (c *Client) Request(method string, path string, body interface{}, result interface{}) error {
+	return c.RequestWithContext(context.Background(), method, path, body, result)
+}
+
+// RequestWithContext is like Request, but takes a context.
+// Idempotent requests that fail are retried according to the client's RetryPolicy.
+// Responses with a status code other than 200 are returned as an *APIError.
+func (c *Client) RequestWithContext(ctx context.Context, method string, path string, body interface{}, result interface{}) error {
 	url := c.url + path
 
-	if body != nil {
-		// Marshal empty arrays instead of "null".  The Confidant API expects these to be arrays.
-		if body.Credentials == nil {
-			body.Credentials = make([]string, 0)
+	switch body := body.(type) {
+	case *RequestBody:
+		if body != nil {
+			// Marshal empty arrays instead of "null".  The Confidant API expects these to be arrays.
+			if body.Credentials == nil {
+				body.Credentials = make([]string, 0)
+			}
+			if body.BlindCredentials == nil {
+				body.BlindCredentials = make([]string, 0)
+			}
 		}
-		if body.BlindCredentials == nil {
-			body.BlindCredentials = make([]string, 0)
+	case *CredentialRequestBody:
+		if body != nil {
+			// Marshal empty objects instead of "null".  The Confidant API expects these to be objects.
+			if body.CredentialPairs == nil {
+				body.CredentialPairs = make(map[string]string)
+			}
+			if body.Metadata == nil {
+				body.Metadata = make(map[string]string)
+			}
+		}
+	case *BlindCredentialRequestBody:
+		if body != nil {
+			if body.CredentialPairs == nil {
+				body.CredentialPairs = make(map[string]string)
+			}
+			if body.CredentialKeys == nil {
+				body.CredentialKeys = make([]string, 0)
+			}
+			if body.DataKey == nil {
+				body.DataKey = make(map[string]string)
+			}
+			if body.Metadata == nil {
+				body.Metadata = make(map[string]string)
+			}
 		}
 	}
 	requestBody, err := json.Marshal(body)
 	if err != nil {
 		return err
 	}
-	req, err := http.NewRequest(method, url, bytes.NewBuffer(requestBody))
+	var req *http.Request
+	var authenticator Authenticator
+	var resp *http.Response
+	var bodyBytes []byte
+	for attempt := 1; ; attempt++ {
+		// Errors building or authenticating the request, such as KMS denying access,
+		// won't go away by retrying, so only errors sending it are retried.
+		req, authenticator, err = c.newRequest(ctx, method, url, requestBody)
+		if err != nil {
+			return err
+		}
+		resp, bodyBytes, err = c.do(req, authenticator)
+		if c.RetryPolicy == nil || !isIdempotent(method, path) || !c.RetryPolicy.Retryable(resp, err) {
+			break
+		}
+		delay, ok := c.RetryPolicy.Backoff(attempt, resp)
+		if !ok {
+			break
+		}
+		if err := sleepContext(ctx, delay); err != nil {
+			return err
+		}
+	}
 	if err != nil {
 		return err
 	}
-	username := c.TokenGenerator.GetUsername()
-	token, err := c.TokenGenerator.GetToken()
+	if resp.StatusCode != http.StatusOK {
+		var errorBody struct {
+			Error string `json:"error"`
+		}
+		// The body isn't always JSON, in which case the raw body is enough.
+		_ = json.Unmarshal(bodyBytes, &errorBody)
+		return &APIError{
+			StatusCode: resp.StatusCode,
+			Method:     method,
+			Path:       path,
+			Message:    errorBody.Error,
+			Body:       string(bodyBytes),
+		}
+	}
+	err = json.Unmarshal(bodyBytes, result)
 	if err != nil {
 		return err
 	}
-	req.Header.Add("X-Auth-From", username)
-	req.Header.Add("X-Auth-Token", token)
+	return nil
+}
+
+// newRequest returns an authenticated request, and the Authenticator that authenticated it.
+func (c *Client) newRequest(ctx context.Context, method string, url string, requestBody []byte) (*http.Request, Authenticator, error) {
+	// Don't ask KMS for a token for a request that won't be made.
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(requestBody))
+	if err != nil {
+		return nil, nil, err
+	}
+	authenticator := c.authenticator()
+	if err := authenticator.Authenticate(req); err != nil {
+		return nil, nil, err
+	}
 	req.Header.Add("Content-Type", "application/json")
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+	return req, authenticator, nil
+}
+
+// do makes a single request and reads the response body.
+func (c *Client) do(req *http.Request, authenticator Authenticator) (*http.Response, []byte, error) {
 	resp, err := c.HttpClient.Do(req)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	defer resp.Body.Close()
-	bodyBytes, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return err
+	if observer, ok := authenticator.(ResponseObserver); ok {
+		observer.ObserveResponse(resp)
 	}
-	if resp.StatusCode == http.StatusNotFound {
-		return fmt.Errorf("NotFound")
-	} else if resp.StatusCode == http.StatusForbidden {
-		return fmt.Errorf("Forbidden")
-	} else if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("Confidant Request Failed: got status code %v with body %s", resp.StatusCode, string(bodyBytes))
-	}
-	err = json.Unmarshal(bodyBytes, result)
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	return nil
+	return resp, bodyBytes, nil
 }
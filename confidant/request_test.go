@@ -1,12 +1,15 @@
 package confidant
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/aws/aws-sdk-go/service/kms"
@@ -23,9 +26,14 @@ func (m *mockKMSClient) Encrypt(input *kms.EncryptInput) (*kms.EncryptOutput, er
 	return &m.Resp, nil
 }
 
+// mockResponse can be used as a response to reply with a status code other than 200.
+type mockResponse struct {
+	StatusCode int
+	Body       interface{}
+}
+
 func createHandlerFunc(t *testing.T, expectedUsername string, expectedToken string, responses map[string]interface{}) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
 		key := r.Method + r.URL.EscapedPath()
 		response, ok := responses[key]
 		if !ok {
@@ -50,7 +58,7 @@ func createHandlerFunc(t *testing.T, expectedUsername string, expectedToken stri
 		if err != nil {
 			t.Errorf("Unable read request body: %s", err)
 		}
-		if string(bodyBytes) != "null" {
+		if string(bodyBytes) != "null" && strings.HasPrefix(r.URL.Path, "/v1/services/") {
 			err = json.Unmarshal(bodyBytes, &request)
 			if err != nil {
 				t.Errorf("Unable to unmarshal body: %s", err)
@@ -63,11 +71,18 @@ func createHandlerFunc(t *testing.T, expectedUsername string, expectedToken stri
 			}
 		}
 
+		statusCode := http.StatusOK
+		if r, ok := response.(mockResponse); ok {
+			statusCode = r.StatusCode
+			response = r.Body
+		}
+		w.WriteHeader(statusCode)
 		json.NewEncoder(w).Encode(response)
 	}
 }
 
-func CreateMockClientAndServer(responses map[string]interface{}, t *testing.T) (*httptest.Server, *Client) {
+// createMockClient returns a client for url which generates tokens with a mock KMS client.
+func createMockClient(url string) *Client {
 	authkey := "key"
 	from := "go-confidant-client"
 	to := "confidant"
@@ -82,12 +97,16 @@ func CreateMockClientAndServer(responses map[string]interface{}, t *testing.T) (
 	generator.KMSClient = &mockKMSClient{
 		Resp: expected,
 	}
-	encodedToken := base64.StdEncoding.EncodeToString([]byte(token))
-	username := fmt.Sprintf("2/user/%s", from)
+	c := NewClient(url, httpClient, &generator)
+	return &c
+}
+
+func CreateMockClientAndServer(responses map[string]interface{}, t *testing.T) (*httptest.Server, *Client) {
+	encodedToken := base64.StdEncoding.EncodeToString([]byte("token"))
+	username := fmt.Sprintf("2/user/%s", "go-confidant-client")
 	handler := createHandlerFunc(t, username, encodedToken, responses)
 	ts := httptest.NewServer(http.HandlerFunc(handler))
-	c := NewClient(ts.URL, httpClient, &generator)
-	return ts, &c
+	return ts, createMockClient(ts.URL)
 }
 
 func TestRequest(t *testing.T) {
@@ -121,3 +140,57 @@ func TestRequest(t *testing.T) {
 		t.Errorf("Expected service revision 1, got %d", services[0].Revision)
 	}
 }
+
+func TestRequestWithContextCanceled(t *testing.T) {
+	var services Services
+	responses := map[string]interface{}{"GET/v1/services": Services{}}
+	ts, c := CreateMockClientAndServer(responses, t)
+	defer ts.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := c.RequestWithContext(ctx, "GET", "/v1/services", nil, &services)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestRequestAPIError(t *testing.T) {
+	var services Services
+	method := "GET"
+	path := "/v1/services/foo"
+	tests := []struct {
+		statusCode int
+		sentinel   error
+	}{
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusForbidden, ErrForbidden},
+		{http.StatusBadRequest, nil},
+	}
+	for _, test := range tests {
+		responses := map[string]interface{}{
+			method + path: mockResponse{StatusCode: test.statusCode, Body: map[string]string{"error": "failed"}},
+		}
+		ts, c := CreateMockClientAndServer(responses, t)
+		err := c.Request(method, path, nil, &services)
+		ts.Close()
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) {
+			t.Fatalf("Expected an *APIError, got %v", err)
+		}
+		expected := APIError{
+			StatusCode: test.statusCode,
+			Method:     method,
+			Path:       path,
+			Message:    "failed",
+			Body:       "{\"error\":\"failed\"}\n",
+		}
+		if *apiErr != expected {
+			t.Errorf("Expected %+v, got %+v", expected, *apiErr)
+		}
+		for _, sentinel := range []error{ErrNotFound, ErrForbidden} {
+			if errors.Is(err, sentinel) != (sentinel == test.sentinel) {
+				t.Errorf("Status code %d: errors.Is(err, %v) was %t", test.statusCode, sentinel, errors.Is(err, sentinel))
+			}
+		}
+	}
+}
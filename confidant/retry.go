@@ -0,0 +1,132 @@
+package confidant
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy decides whether and when failed requests are retried.
+// Only idempotent requests are retried: GET, HEAD, OPTIONS and DELETE requests, and PUT
+// requests to services and grants. Other PUT requests, such as those updating a credential,
+// create a new revision every time, so they are never retried.
+type RetryPolicy interface {
+	// Retryable reports whether a request that ended with resp or err can be retried.
+	// resp is nil if err is not. err is an error sending the request or reading the response;
+	// errors building or authenticating a request are returned without asking.
+	Retryable(resp *http.Response, err error) bool
+	// Backoff returns how long to wait before retry number attempt (starting at 1),
+	// or false if no more attempts should be made.
+	// resp is the response to the previous attempt, which may be nil.
+	Backoff(attempt int, resp *http.Response) (time.Duration, bool)
+}
+
+// ExponentialBackoff is a RetryPolicy that retries transport errors, 429 and 5xx responses.
+// The delay before each retry doubles from BaseDelay up to MaxDelay, and a random
+// jitter of up to half the delay is subtracted so that clients don't retry in lockstep.
+// A Retry-After header on the response is used as the delay instead, if present,
+// but is also limited to MaxDelay.
+type ExponentialBackoff struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+// DefaultRetryPolicy returns the RetryPolicy used by NewClient.
+func DefaultRetryPolicy() *ExponentialBackoff {
+	return &ExponentialBackoff{
+		MaxRetries: 9,
+		BaseDelay:  500 * time.Millisecond,
+		MaxDelay:   10 * time.Second,
+	}
+}
+
+func (b *ExponentialBackoff) Retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func (b *ExponentialBackoff) Backoff(attempt int, resp *http.Response) (time.Duration, bool) {
+	if attempt > b.MaxRetries {
+		return 0, false
+	}
+	if delay, ok := retryAfter(resp); ok {
+		if delay > b.MaxDelay {
+			delay = b.MaxDelay
+		}
+		return delay, true
+	}
+	delay := b.BaseDelay
+	for i := 1; i < attempt && delay < b.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > b.MaxDelay {
+		delay = b.MaxDelay
+	}
+	if delay <= 0 {
+		return 0, true
+	}
+	jitter := time.Duration(rand.Int63n(int64(delay)/2 + 1))
+	return delay - jitter, true
+}
+
+// retryAfter parses the Retry-After header of a response, in seconds or as an HTTP date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	header := resp.Header.Get("Retry-After")
+	if header == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
+
+// isIdempotent reports whether requests with the method to the path can safely be retried.
+func isIdempotent(method string, path string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "DELETE":
+		return true
+	case "PUT":
+		// Services and grants are set to the request body, so repeating the request
+		// changes nothing; credentials and blind credentials get a new revision.
+		return strings.HasPrefix(path, "/v1/services/") || strings.HasPrefix(path, "/v1/grants/")
+	}
+	return false
+}
+
+// sleepContext waits for the delay, or returns early with an error if ctx is done.
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,231 @@
+package confidant
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func fastRetryPolicy() *ExponentialBackoff {
+	return &ExponentialBackoff{
+		MaxRetries: 3,
+		BaseDelay:  time.Millisecond,
+		MaxDelay:   5 * time.Millisecond,
+	}
+}
+
+func TestRequestRetries(t *testing.T) {
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"services": [{"id": "test"}]}`))
+	}))
+	defer ts.Close()
+	c := createMockClient(ts.URL)
+	c.RetryPolicy = fastRetryPolicy()
+	services, err := c.GetServices()
+	if err != nil {
+		t.Fatalf("Could not get services: %s", err)
+	}
+	if hits != 3 {
+		t.Errorf("Expected 3 requests, got %d", hits)
+	}
+	if len(services.Services) != 1 {
+		t.Errorf("Expected 1 service, got %d", len(services.Services))
+	}
+}
+
+func TestRequestRetriesExhausted(t *testing.T) {
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+	c := createMockClient(ts.URL)
+	c.RetryPolicy = fastRetryPolicy()
+	_, err := c.GetServices()
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Expected an *APIError with status code 429, got %v", err)
+	}
+	if hits := atomic.LoadInt32(&hits); hits != 4 {
+		t.Errorf("Expected 4 requests, got %d", hits)
+	}
+}
+
+func TestRequestDoesNotRetryNonIdempotent(t *testing.T) {
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+	c := createMockClient(ts.URL)
+	c.RetryPolicy = fastRetryPolicy()
+	tests := []struct {
+		method string
+		path   string
+	}{
+		{"POST", "/v1/credentials"},
+		{"PUT", "/v1/credentials/id"},
+		{"PUT", "/v1/credentials/id/1"},
+		{"PUT", "/v1/blind_credentials/id"},
+	}
+	for _, test := range tests {
+		atomic.StoreInt32(&hits, 0)
+		var result map[string]interface{}
+		if err := c.Request(test.method, test.path, nil, &result); err == nil {
+			t.Errorf("%s %s: expected an error", test.method, test.path)
+		}
+		if hits != 1 {
+			t.Errorf("%s %s: expected 1 request, got %d", test.method, test.path, hits)
+		}
+	}
+}
+
+func TestRequestRetriesServiceUpdate(t *testing.T) {
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"id": "test"}`))
+	}))
+	defer ts.Close()
+	c := createMockClient(ts.URL)
+	c.RetryPolicy = fastRetryPolicy()
+	var result map[string]interface{}
+	if err := c.Request("PUT", "/v1/services/test", &RequestBody{}, &result); err != nil {
+		t.Errorf("Expected the service update to be retried, got %v", err)
+	}
+	if hits != 2 {
+		t.Errorf("Expected 2 requests, got %d", hits)
+	}
+}
+
+func TestRequestRetriesTransportErrors(t *testing.T) {
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	}))
+	defer ts.Close()
+	c := createMockClient(ts.URL)
+	c.RetryPolicy = fastRetryPolicy()
+	if _, err := c.GetServices(); err == nil {
+		t.Errorf("Expected the connection error")
+	}
+	if hits := atomic.LoadInt32(&hits); hits != 4 {
+		t.Errorf("Expected 4 requests, got %d", hits)
+	}
+}
+
+// failingAuthenticator fails to authenticate every request.
+type failingAuthenticator struct {
+	calls int32
+}
+
+func (a *failingAuthenticator) Authenticate(req *http.Request) error {
+	atomic.AddInt32(&a.calls, 1)
+	return errors.New("AccessDeniedException: not authorized to use the key")
+}
+
+func TestRequestDoesNotRetryAuthenticationErrors(t *testing.T) {
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+	}))
+	defer ts.Close()
+	c := createMockClient(ts.URL)
+	c.RetryPolicy = fastRetryPolicy()
+	authenticator := &failingAuthenticator{}
+	c.Authenticator = authenticator
+	if _, err := c.GetServices(); err == nil {
+		t.Errorf("Expected the authentication error")
+	}
+	if authenticator.calls != 1 || hits != 0 {
+		t.Errorf("Expected 1 authentication attempt and no requests, got %d and %d", authenticator.calls, hits)
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	policy := &ExponentialBackoff{MaxRetries: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	expected := []time.Duration{100, 200, 400, 800, 1000}
+	for i, max := range expected {
+		max *= time.Millisecond
+		delay, ok := policy.Backoff(i+1, nil)
+		if !ok {
+			t.Fatalf("Attempt %d: expected a retry", i+1)
+		}
+		if delay > max || delay < max/2 {
+			t.Errorf("Attempt %d: expected a delay between %s and %s, got %s", i+1, max/2, max, delay)
+		}
+	}
+	if _, ok := policy.Backoff(6, nil); ok {
+		t.Errorf("Expected no retry after MaxRetries")
+	}
+	resp := &http.Response{Header: http.Header{"Retry-After": []string{"3"}}}
+	if delay, _ := DefaultRetryPolicy().Backoff(1, resp); delay != 3*time.Second {
+		t.Errorf("Expected the Retry-After delay of 3s, got %s", delay)
+	}
+	if delay, _ := policy.Backoff(1, resp); delay != time.Second {
+		t.Errorf("Expected the Retry-After delay to be limited to MaxDelay, got %s", delay)
+	}
+}
+
+func TestExponentialBackoffRetryable(t *testing.T) {
+	policy := DefaultRetryPolicy()
+	tests := []struct {
+		statusCode int
+		retryable  bool
+	}{
+		{http.StatusOK, false},
+		{http.StatusNotFound, false},
+		{http.StatusTooManyRequests, true},
+		{http.StatusInternalServerError, true},
+		{http.StatusBadGateway, true},
+		{http.StatusServiceUnavailable, true},
+	}
+	for _, test := range tests {
+		resp := &http.Response{StatusCode: test.statusCode}
+		if policy.Retryable(resp, nil) != test.retryable {
+			t.Errorf("Status code %d: expected retryable to be %t", test.statusCode, test.retryable)
+		}
+	}
+	if !policy.Retryable(nil, errors.New("connection reset by peer")) {
+		t.Errorf("Expected connection errors to be retryable")
+	}
+	if policy.Retryable(nil, context.Canceled) {
+		t.Errorf("Expected canceled requests not to be retryable")
+	}
+}
+
+func TestEnsureGrantsRetries(t *testing.T) {
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) < 3 {
+			w.Write([]byte(`{"grants": {"encrypt_grant": true, "decrypt_grant": false}}`))
+			return
+		}
+		w.Write([]byte(`{"grants": {"encrypt_grant": true, "decrypt_grant": true}}`))
+	}))
+	defer ts.Close()
+	c := createMockClient(ts.URL)
+	c.RetryPolicy = fastRetryPolicy()
+	if err := c.EnsureGrants("service-name"); err != nil {
+		t.Errorf("Could not ensure grants: %s", err)
+	}
+	if hits != 3 {
+		t.Errorf("Expected 3 requests, got %d", hits)
+	}
+}
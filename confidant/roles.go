@@ -1,8 +1,6 @@
 package confidant
 
-import (
-	"errors"
-)
+import "context"
 
 type Roles struct {
 	Result bool     `json:"result"`
@@ -20,14 +18,20 @@ func sliceContains(slice []string, element string) bool {
 
 // CheckRole checks if the service name is a valid IAM role.
 // The roles are fetched with a GET request to /v1/roles.
+// If it is not a valid IAM role, ErrInvalidRole is returned.
 func (c *Client) CheckRole(serviceName string) error {
+	return c.CheckRoleWithContext(context.Background(), serviceName)
+}
+
+// CheckRoleWithContext is like CheckRole, but takes a context.
+func (c *Client) CheckRoleWithContext(ctx context.Context, serviceName string) error {
 	var roles Roles
-	err := c.Request("GET", "/v1/roles", nil, &roles)
+	err := c.RequestWithContext(ctx, "GET", "/v1/roles", nil, &roles)
 	if err != nil {
 		return err
 	}
 	if !sliceContains(roles.Roles, serviceName) {
-		return errors.New("Invalid IAM Role")
+		return ErrInvalidRole
 	}
 	return nil
 }
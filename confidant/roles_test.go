@@ -1,6 +1,7 @@
 package confidant
 
 import (
+	"errors"
 	"testing"
 )
 
@@ -36,3 +37,13 @@ func TestCheckRole(t *testing.T) {
 		}
 	}
 }
+
+func TestCheckRoleInvalid(t *testing.T) {
+	responses := map[string]interface{}{"GET/v1/roles": Roles{Result: true, Roles: []string{}}}
+	ts, c := CreateMockClientAndServer(responses, t)
+	defer ts.Close()
+	err := c.CheckRole("service-name")
+	if !errors.Is(err, ErrInvalidRole) {
+		t.Errorf("Expected ErrInvalidRole, got %v", err)
+	}
+}
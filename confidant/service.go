@@ -1,6 +1,7 @@
 package confidant
 
 import (
+	"context"
 	"errors"
 	"fmt"
 )
@@ -16,67 +17,87 @@ type ServiceResponse struct {
 }
 
 type Service struct {
-	Enabled          bool          `json:"enabled"`
-	ID               string        `json:"id"`
-	Revision         int           `json:"revision"`
-	Credentials      []*Credential `json:"credentials"`
-	BlindCredentials []*Credential `json:"blind_credentials"`
-	Account          string        `json:"account"`
-	Error            string        `json:"error"`
-	ModifiedBy       string        `json:"modified_by"`
-	ModifiedDate     string        `json:"modified_date"`
+	Enabled          bool               `json:"enabled"`
+	ID               string             `json:"id"`
+	Revision         int                `json:"revision"`
+	Credentials      []*Credential      `json:"credentials"`
+	BlindCredentials []*BlindCredential `json:"blind_credentials"`
+	Account          string             `json:"account"`
+	Error            string             `json:"error"`
+	ModifiedBy       string             `json:"modified_by"`
+	ModifiedDate     string             `json:"modified_date"`
 }
 
 // GetServices fetches the list of services
 // It returns a pointer to a Services struct
 func (c *Client) GetServices() (*Services, error) {
+	return c.GetServicesWithContext(context.Background())
+}
+
+// GetServicesWithContext is like GetServices, but takes a context.
+func (c *Client) GetServicesWithContext(ctx context.Context) (*Services, error) {
 	var services Services
-	err := c.Request("GET", "/v1/services", nil, &services)
+	err := c.RequestWithContext(ctx, "GET", "/v1/services", nil, &services)
 	if err != nil {
-		return nil, fmt.Errorf("Got an error when making the Confidant request: %e", err)
+		return nil, fmt.Errorf("Got an error when making the Confidant request: %w", err)
 	}
 	return &services, nil
 }
 
 // GetService fetches details for a service.
-// It returns a pointer to a Service struct.
+// Services are cached according to the client's ServiceCacheTTL and ServiceCacheSize.
+// It returns a pointer to a copy of the Service struct, which callers may change.
+// If the service does not exist, the error matches ErrNotFound.
 func (c *Client) GetService(serviceName string) (*Service, error) {
-	if service, ok := c.services[serviceName]; ok {
+	return c.GetServiceWithContext(context.Background(), serviceName)
+}
+
+// GetServiceWithContext is like GetService, but takes a context.
+func (c *Client) GetServiceWithContext(ctx context.Context, serviceName string) (*Service, error) {
+	if service, ok := c.cachedService(serviceName); ok {
 		return service, nil
 	}
+	return c.fetchService(ctx, serviceName)
+}
+
+// fetchService fetches details for a service from Confidant, bypassing the cache,
+// so that updates are based on the latest revision of the service.
+func (c *Client) fetchService(ctx context.Context, serviceName string) (*Service, error) {
 	var service Service
-	err := c.Request("GET", "/v1/services/"+serviceName, nil, &service)
+	err := c.RequestWithContext(ctx, "GET", "/v1/services/"+serviceName, nil, &service)
 	if err != nil {
-		if err.Error() == "NotFound" {
-			return nil, errors.New("Service Doesn't Exist")
-		} else {
-			return nil, err
-		}
+		return nil, err
 	} else if service.Error != "" {
-		return nil, errors.New(service.Error)
+		return nil, bodyError("GET", "/v1/services/"+serviceName, service.Error)
 	}
-	c.services[serviceName] = &service
+	c.cacheService(serviceName, &service)
 	return &service, nil
 }
 
 // CreateService creates a new service.
 // It returns a pointer to a Service struct.
+// If the service already exists, it ensures its grants and returns it along with ErrServiceExists.
 func (c *Client) CreateService(serviceName string, credentialNames []string) (*Service, error) {
-	service, err := c.GetService(serviceName)
+	return c.CreateServiceWithContext(context.Background(), serviceName, credentialNames)
+}
+
+// CreateServiceWithContext is like CreateService, but takes a context.
+func (c *Client) CreateServiceWithContext(ctx context.Context, serviceName string, credentialNames []string) (*Service, error) {
+	service, err := c.fetchService(ctx, serviceName)
 	if err == nil {
-		err = c.EnsureGrants(serviceName)
+		err = c.EnsureGrantsWithContext(ctx, serviceName)
 		if err != nil {
-			return service, fmt.Errorf("Service already exists, but got an error when trying to ensure grants: %e", err)
+			return service, fmt.Errorf("Service already exists, but got an error when trying to ensure grants: %w", err)
 		}
-		return service, errors.New("Service Already Exists")
-	} else if err.Error() != "Service Doesn't Exist" {
+		return service, ErrServiceExists
+	} else if !errors.Is(err, ErrNotFound) {
 		return nil, err
 	}
-	err = c.CheckRole(serviceName)
+	err = c.CheckRoleWithContext(ctx, serviceName)
 	if err != nil {
 		return nil, err
 	}
-	credentials, err := c.FindCredentialsByName(credentialNames)
+	credentials, err := c.FindCredentialsByNameWithContext(ctx, credentialNames)
 	if err != nil {
 		return nil, err
 	}
@@ -89,34 +110,40 @@ func (c *Client) CreateService(serviceName string, credentialNames []string) (*S
 		Enabled:     true,
 	}
 	var response ServiceResponse
-	err = c.Request("PUT", "/v1/services/"+serviceName, &body, &response)
+	err = c.RequestWithContext(ctx, "PUT", "/v1/services/"+serviceName, &body, &response)
 	if err != nil {
 		return nil, err
 	} else if response.Error != "" {
-		return nil, errors.New(response.Error)
+		return nil, bodyError("PUT", "/v1/services/"+serviceName, response.Error)
 	}
 	if response.Service.Revision != 0 {
-		err := c.EnsureGrants(serviceName)
+		err := c.EnsureGrantsWithContext(ctx, serviceName)
 		if err != nil {
 			return nil, err
 		}
 	}
-	c.services[serviceName] = &response.Service
+	c.cacheService(serviceName, &response.Service)
 	return &response.Service, nil
 }
 
-// SetServiceCredentials sets the credentials for an existing service.
+// SetServiceCredentials sets the credentials for an existing service,
+// keeping its blind credentials.
 // It returns a pointer to a Service struct.
 func (c *Client) SetServiceCredentials(serviceName string, credentialNames []string) (*Service, error) {
-	service, err := c.GetService(serviceName)
+	return c.SetServiceCredentialsWithContext(context.Background(), serviceName, credentialNames)
+}
+
+// SetServiceCredentialsWithContext is like SetServiceCredentials, but takes a context.
+func (c *Client) SetServiceCredentialsWithContext(ctx context.Context, serviceName string, credentialNames []string) (*Service, error) {
+	service, err := c.fetchService(ctx, serviceName)
 	if err != nil {
 		return nil, err
 	}
-	err = c.EnsureGrants(serviceName)
+	err = c.EnsureGrantsWithContext(ctx, serviceName)
 	if err != nil {
 		return nil, err
 	}
-	credentials, err := c.FindCredentialsByName(credentialNames)
+	credentials, err := c.FindCredentialsByNameWithContext(ctx, credentialNames)
 	if err != nil {
 		return nil, err
 	}
@@ -127,43 +154,48 @@ func (c *Client) SetServiceCredentials(serviceName string, credentialNames []str
 
 	body := RequestBody{
 		Credentials:      credentialIDs,
-		BlindCredentials: []string{},
+		BlindCredentials: getBlindCredentialIDs(service.BlindCredentials),
 		Account:          service.Account,
 		Enabled:          service.Enabled,
 	}
 	var response Service
-	err = c.Request("PUT", "/v1/services/"+serviceName, &body, &response)
+	err = c.RequestWithContext(ctx, "PUT", "/v1/services/"+serviceName, &body, &response)
 	if err != nil {
 		return nil, err
 	} else if response.Error != "" {
-		return nil, errors.New(response.Error)
+		return nil, bodyError("PUT", "/v1/services/"+serviceName, response.Error)
 	}
 	if response.Revision != 0 {
-		err := c.EnsureGrants(serviceName)
+		err := c.EnsureGrantsWithContext(ctx, serviceName)
 		if err != nil {
-			return nil, fmt.Errorf("Could not ensure grants: %e", err)
+			return nil, fmt.Errorf("Could not ensure grants: %w", err)
 		}
 	}
-	c.services[serviceName] = &response
+	c.cacheService(serviceName, &response)
 	return &response, nil
 }
 
 // UpdateServiceCredentials updates an existing service by adding or removing credentials.
 // It returns a pointer to a Service struct.
 func (c *Client) UpdateServiceCredentials(serviceName string, addCredentialNames []string, removeCredentialNames []string) (*Service, error) {
-	service, err := c.GetService(serviceName)
+	return c.UpdateServiceCredentialsWithContext(context.Background(), serviceName, addCredentialNames, removeCredentialNames)
+}
+
+// UpdateServiceCredentialsWithContext is like UpdateServiceCredentials, but takes a context.
+func (c *Client) UpdateServiceCredentialsWithContext(ctx context.Context, serviceName string, addCredentialNames []string, removeCredentialNames []string) (*Service, error) {
+	service, err := c.fetchService(ctx, serviceName)
 	if err != nil {
 		return nil, err
 	}
-	err = c.EnsureGrants(serviceName)
+	err = c.EnsureGrantsWithContext(ctx, serviceName)
 	if err != nil {
 		return nil, err
 	}
-	addCredentials, err := c.FindCredentialsByName(addCredentialNames)
+	addCredentials, err := c.FindCredentialsByNameWithContext(ctx, addCredentialNames)
 	if err != nil {
 		return nil, err
 	}
-	removeCredentials, err := c.FindCredentialsByName(removeCredentialNames)
+	removeCredentials, err := c.FindCredentialsByNameWithContext(ctx, removeCredentialNames)
 	if err != nil {
 		return nil, err
 	}
@@ -172,36 +204,41 @@ func (c *Client) UpdateServiceCredentials(serviceName string, addCredentialNames
 
 	body := RequestBody{
 		Credentials:      credentialIDs,
-		BlindCredentials: []string{},
+		BlindCredentials: getBlindCredentialIDs(service.BlindCredentials),
 		Account:          service.Account,
 		Enabled:          service.Enabled,
 	}
 	var response Service
-	err = c.Request("PUT", "/v1/services/"+serviceName, &body, &response)
+	err = c.RequestWithContext(ctx, "PUT", "/v1/services/"+serviceName, &body, &response)
 	if err != nil {
 		return nil, err
 	} else if response.Error != "" {
-		return nil, errors.New(response.Error)
+		return nil, bodyError("PUT", "/v1/services/"+serviceName, response.Error)
 	}
 	if response.Revision != 0 {
-		err := c.EnsureGrants(serviceName)
+		err := c.EnsureGrantsWithContext(ctx, serviceName)
 		if err != nil {
-			return nil, fmt.Errorf("Could not ensure grants: %e", err)
+			return nil, fmt.Errorf("Could not ensure grants: %w", err)
 		}
 	}
-	c.services[serviceName] = &response
+	c.cacheService(serviceName, &response)
 	return &response, nil
 }
 
 // EnableService updates an existing service by setting enabled to true
 // It returns a pointer to a Service struct.
 func (c *Client) EnableService(serviceName string) (*Service, error) {
-	service, err := c.GetService(serviceName)
+	return c.EnableServiceWithContext(context.Background(), serviceName)
+}
+
+// EnableServiceWithContext is like EnableService, but takes a context.
+func (c *Client) EnableServiceWithContext(ctx context.Context, serviceName string) (*Service, error) {
+	service, err := c.fetchService(ctx, serviceName)
 	if err != nil {
 		return nil, err
 	}
 
-	err = c.EnsureGrants(serviceName)
+	err = c.EnsureGrantsWithContext(ctx, serviceName)
 	if err != nil {
 		return nil, err
 	}
@@ -209,49 +246,96 @@ func (c *Client) EnableService(serviceName string) (*Service, error) {
 
 	body := RequestBody{
 		Credentials:      credentialIDs,
-		BlindCredentials: []string{},
+		BlindCredentials: getBlindCredentialIDs(service.BlindCredentials),
 		Account:          service.Account,
 		Enabled:          true,
 	}
 	var response Service
-	err = c.Request("PUT", "/v1/services/"+serviceName, &body, &response)
+	err = c.RequestWithContext(ctx, "PUT", "/v1/services/"+serviceName, &body, &response)
 	if err != nil {
 		return nil, err
 	} else if response.Error != "" {
-		return nil, errors.New(response.Error)
+		return nil, bodyError("PUT", "/v1/services/"+serviceName, response.Error)
 	}
 	if response.Revision != 0 {
-		err := c.EnsureGrants(serviceName)
+		err := c.EnsureGrantsWithContext(ctx, serviceName)
 		if err != nil {
 			return nil, err
 		}
 	}
-	c.services[serviceName] = &response
+	c.cacheService(serviceName, &response)
 	return &response, nil
 }
 
 // DisableService updates an existing service by setting Enabled to false
-// and Credentials to empty.
+// and Credentials to empty. Its blind credentials are kept.
 // It returns a pointer to a Service struct.
 func (c *Client) DisableService(serviceName string) (*Service, error) {
-	service, err := c.GetService(serviceName)
+	return c.DisableServiceWithContext(context.Background(), serviceName)
+}
+
+// DisableServiceWithContext is like DisableService, but takes a context.
+func (c *Client) DisableServiceWithContext(ctx context.Context, serviceName string) (*Service, error) {
+	service, err := c.fetchService(ctx, serviceName)
 	if err != nil {
 		return nil, err
 	}
 
 	body := RequestBody{
 		Credentials:      []string{},
-		BlindCredentials: []string{},
+		BlindCredentials: getBlindCredentialIDs(service.BlindCredentials),
 		Account:          service.Account,
 		Enabled:          false,
 	}
 	var response Service
-	err = c.Request("PUT", "/v1/services/"+serviceName, &body, &response)
+	err = c.RequestWithContext(ctx, "PUT", "/v1/services/"+serviceName, &body, &response)
 	if err != nil {
 		return nil, err
 	} else if response.Error != "" {
-		return nil, errors.New(response.Error)
+		return nil, bodyError("PUT", "/v1/services/"+serviceName, response.Error)
 	}
-	c.services[serviceName] = &response
+	c.cacheService(serviceName, &response)
 	return &response, nil
 }
+
+// ServiceCredentials returns the decrypted credential pairs of all the credentials
+// assigned to a service, merged into a single map, like the Python confidant-client's get_service.
+// Confidant only returns the pairs to the service itself, so the client's TokenGenerator
+// should generate "service" tokens from the service's name.
+// Blind credentials are not included; decrypt them with DecryptBlindCredentialPairs.
+// The service is always fetched from Confidant, not the service cache, so the pairs are current.
+// If more than one credential has the same key, it returns a *ConflictingKeysError instead.
+func (c *Client) ServiceCredentials(serviceName string) (map[string]string, error) {
+	return c.ServiceCredentialsWithContext(context.Background(), serviceName)
+}
+
+// ServiceCredentialsWithContext is like ServiceCredentials, but takes a context.
+func (c *Client) ServiceCredentialsWithContext(ctx context.Context, serviceName string) (map[string]string, error) {
+	service, err := c.fetchService(ctx, serviceName)
+	if err != nil {
+		return nil, err
+	}
+	return mergeCredentialPairs(serviceName, service.Credentials)
+}
+
+// mergeCredentialPairs merges the pairs of credentials, checking that no key is in more than one.
+func mergeCredentialPairs(serviceName string, credentials []*Credential) (map[string]string, error) {
+	pairs := make(map[string]string)
+	owners := make(map[string][]string)
+	for _, credential := range credentials {
+		for key, value := range credential.CredentialPairs {
+			pairs[key] = value
+			owners[key] = append(owners[key], credential.Name)
+		}
+	}
+	conflicts := make(map[string][]string)
+	for key, names := range owners {
+		if len(names) > 1 {
+			conflicts[key] = names
+		}
+	}
+	if len(conflicts) != 0 {
+		return nil, &ConflictingKeysError{Service: serviceName, Conflicts: conflicts}
+	}
+	return pairs, nil
+}
@@ -1,7 +1,13 @@
 package confidant
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"sync/atomic"
 	"testing"
 )
 
@@ -79,7 +85,7 @@ func TestGetService(t *testing.T) {
 		ID:               serviceName,
 		Enabled:          true,
 		Account:          "",
-		BlindCredentials: make([]*Credential, 0),
+		BlindCredentials: make([]*BlindCredential, 0),
 		Credentials:      credentials,
 		Revision:         1,
 		ModifiedBy:       "username",
@@ -92,7 +98,7 @@ func TestGetService(t *testing.T) {
 	if err != nil {
 		t.Errorf("Could not get service: %e", err)
 	}
-	if _, ok := c.services[serviceName]; !ok {
+	if _, ok := c.cachedService(serviceName); !ok {
 		t.Errorf("client.services does not contain %s", serviceName)
 	}
 	testService(service, &expectedService, t)
@@ -115,7 +121,7 @@ func TestCreateService(t *testing.T) {
 		ID:               serviceName,
 		Enabled:          true,
 		Account:          "",
-		BlindCredentials: make([]*Credential, 0),
+		BlindCredentials: make([]*BlindCredential, 0),
 		Credentials:      credentials,
 		Revision:         0,
 		ModifiedBy:       "username",
@@ -123,7 +129,7 @@ func TestCreateService(t *testing.T) {
 	}
 	responses := make(map[string]interface{})
 	responses["PUT"+path] = ServiceResponse{Result: true, Service: expectedService}
-	responses["GET/v1/services/"+serviceName] = ServiceResponse{Result: false, Error: "Service Doesn't Exist"}
+	responses["GET/v1/services/"+serviceName] = mockResponse{StatusCode: http.StatusNotFound, Body: map[string]string{}}
 	responses["GET/v1/roles"] = Roles{Roles: []string{serviceName}}
 	responses["GET/v1/credentials"] = CredentialResponse{Credentials: []Credential{credential}}
 	ts, c := CreateMockClientAndServer(responses, t)
@@ -132,12 +138,41 @@ func TestCreateService(t *testing.T) {
 	if err != nil {
 		t.Errorf("Could not create service: %e", err)
 	}
-	if _, ok := c.services[serviceName]; !ok {
+	if _, ok := c.cachedService(serviceName); !ok {
 		t.Errorf("client.services does not contain %s", serviceName)
 	}
 	testService(service, &expectedService, t)
 }
 
+func TestGetServiceNotFound(t *testing.T) {
+	serviceName := "foo"
+	responses := map[string]interface{}{
+		"GET/v1/services/" + serviceName: mockResponse{StatusCode: http.StatusNotFound, Body: map[string]string{}},
+	}
+	ts, c := CreateMockClientAndServer(responses, t)
+	defer ts.Close()
+	_, err := c.GetService(serviceName)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestCreateServiceExists(t *testing.T) {
+	serviceName := "foo"
+	existing := Service{ID: serviceName, Enabled: true, Revision: 1}
+	grants := Grants{EncryptGrant: true, DecryptGrant: true}
+	responses := make(map[string]interface{})
+	responses["GET/v1/services/"+serviceName] = existing
+	responses["PUT/v1/grants/"+serviceName] = GrantsResponse{Grants: grants}
+	ts, c := CreateMockClientAndServer(responses, t)
+	defer ts.Close()
+	service, err := c.CreateService(serviceName, []string{})
+	if !errors.Is(err, ErrServiceExists) {
+		t.Errorf("Expected ErrServiceExists, got %v", err)
+	}
+	testService(service, &existing, t)
+}
+
 func TestSetServiceCredentials(t *testing.T) {
 	serviceName := "foo"
 	path := "/v1/services/" + serviceName
@@ -176,7 +211,7 @@ func TestSetServiceCredentials(t *testing.T) {
 	if err != nil {
 		t.Errorf("Could not update service: %e", err)
 	}
-	if _, ok := c.services[serviceName]; !ok {
+	if _, ok := c.cachedService(serviceName); !ok {
 		t.Errorf("client.services does not contain %s", serviceName)
 	}
 	testService(service, &expectedService, t)
@@ -220,7 +255,7 @@ func TestUpdateServiceCredentials(t *testing.T) {
 	if err != nil {
 		t.Errorf("Could not update service: %e", err)
 	}
-	if _, ok := c.services[serviceName]; !ok {
+	if _, ok := c.cachedService(serviceName); !ok {
 		t.Errorf("client.services does not contain %s", serviceName)
 	}
 	testService(service, &expectedService, t)
@@ -252,7 +287,7 @@ func TestEnableService(t *testing.T) {
 	if err != nil {
 		t.Errorf("Could not enable service: %e", err)
 	}
-	if _, ok := c.services[serviceName]; !ok {
+	if _, ok := c.cachedService(serviceName); !ok {
 		t.Errorf("client.services does not contain %s", serviceName)
 	}
 	testService(service, &expectedService, t)
@@ -282,8 +317,74 @@ func TestDisableService(t *testing.T) {
 	if err != nil {
 		t.Errorf("Could not disable service: %e", err)
 	}
-	if _, ok := c.services[serviceName]; !ok {
+	if _, ok := c.cachedService(serviceName); !ok {
 		t.Errorf("client.services does not contain %s", serviceName)
 	}
 	testService(service, &expectedService, t)
 }
+
+func TestServiceCredentials(t *testing.T) {
+	serviceName := "service-name"
+	database := Credential{ID: "1", Name: "database", CredentialPairs: map[string]string{"db_user": "admin", "db_password": "hunter2"}}
+	api := Credential{ID: "2", Name: "api", CredentialPairs: map[string]string{"api_key": "secret"}}
+	responses := map[string]interface{}{
+		"GET/v1/services/" + serviceName: Service{ID: serviceName, Enabled: true, Credentials: []*Credential{&database, &api}},
+	}
+	ts, c := CreateMockClientAndServer(responses, t)
+	defer ts.Close()
+	pairs, err := c.ServiceCredentials(serviceName)
+	if err != nil {
+		t.Fatalf("Could not get service credentials: %s", err)
+	}
+	expected := map[string]string{"db_user": "admin", "db_password": "hunter2", "api_key": "secret"}
+	if !reflect.DeepEqual(pairs, expected) {
+		t.Errorf("Expected credential pairs %v, got %v", expected, pairs)
+	}
+}
+
+func TestServiceCredentialsNotCached(t *testing.T) {
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		password := fmt.Sprintf("password-%d", atomic.AddInt32(&hits, 1))
+		credential := Credential{ID: "1", Name: "database", CredentialPairs: map[string]string{"db_password": password}}
+		json.NewEncoder(w).Encode(Service{ID: "service-name", Enabled: true, Credentials: []*Credential{&credential}})
+	}))
+	defer ts.Close()
+	c := createMockClient(ts.URL)
+	if _, err := c.GetService("service-name"); err != nil {
+		t.Fatalf("Could not get service: %s", err)
+	}
+	pairs, err := c.ServiceCredentials("service-name")
+	if err != nil {
+		t.Fatalf("Could not get service credentials: %s", err)
+	}
+	if pairs["db_password"] != "password-2" {
+		t.Errorf("Expected the credentials to be fetched rather than cached, got %v", pairs)
+	}
+}
+
+func TestServiceCredentialsConflict(t *testing.T) {
+	serviceName := "service-name"
+	first := Credential{ID: "1", Name: "first", CredentialPairs: map[string]string{"api_key": "one", "user": "a"}}
+	second := Credential{ID: "2", Name: "second", CredentialPairs: map[string]string{"api_key": "two"}}
+	responses := map[string]interface{}{
+		"GET/v1/services/" + serviceName: Service{ID: serviceName, Enabled: true, Credentials: []*Credential{&first, &second}},
+	}
+	ts, c := CreateMockClientAndServer(responses, t)
+	defer ts.Close()
+	_, err := c.ServiceCredentials(serviceName)
+	if !errors.Is(err, ErrConflictingKeys) {
+		t.Fatalf("Expected error to match ErrConflictingKeys, got %v", err)
+	}
+	var conflictErr *ConflictingKeysError
+	if !errors.As(err, &conflictErr) {
+		t.Fatalf("Expected a *ConflictingKeysError, got %T", err)
+	}
+	expected := map[string][]string{"api_key": {"first", "second"}}
+	if !reflect.DeepEqual(conflictErr.Conflicts, expected) {
+		t.Errorf("Expected conflicts %v, got %v", expected, conflictErr.Conflicts)
+	}
+	if err.Error() != "Credentials of service service-name have conflicting keys: api_key in [first second]" {
+		t.Errorf("Unexpected error message: %s", err)
+	}
+}
@@ -0,0 +1,192 @@
+package kmsauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stripe/go-confidant-client/kmsauth/kmsv1"
+)
+
+const (
+	// DefaultFailoverCooldown is how long a FailoverEncrypter skips a region after KMS fails there.
+	DefaultFailoverCooldown = time.Minute
+	// DefaultRegionTimeout is how long a FailoverEncrypter waits for KMS in a region
+	// before failing over to the next one.
+	DefaultRegionTimeout = 2 * time.Second
+)
+
+// RegionKey is a KMS key to encrypt tokens with, in one region.
+type RegionKey struct {
+	Region string
+	KeyID  string
+	// Encrypter calls KMS in Region. If nil, NewFailoverTokenGenerator creates one
+	// for Region with WithKMSFactory, or an SDK v1 client from session.New().
+	Encrypter Encrypter
+}
+
+// FailoverEncrypter is an Encrypter that tries its keys in order, and falls back to the next
+// region when KMS returns an error or times out. A region that fails is skipped for Cooldown,
+// unless every region is failing. The keyID passed to Encrypt is ignored in favour of each
+// region's KeyID. It is safe to use from multiple goroutines.
+type FailoverEncrypter struct {
+	Keys []RegionKey
+	// Cooldown is how long a failed region is skipped for. Zero means DefaultFailoverCooldown.
+	Cooldown time.Duration
+	// Timeout limits each region's KMS call, so that a region that hangs fails over.
+	// Zero means DefaultRegionTimeout; a negative value means only the caller's context does.
+	Timeout time.Duration
+
+	mu       sync.Mutex
+	failedAt map[string]time.Time
+	now      func() time.Time
+}
+
+// NewFailoverTokenGenerator returns a TokenGenerator for tokens from "from" to "to",
+// encrypted in the first healthy region of keys. Keys without an Encrypter get one
+// created by WithKMSFactory, or an SDK v1 client from session.New(); WithEncrypter,
+// WithKMS and WithKMSClient are ignored.
+// The Confidant server must be able to decrypt tokens encrypted with any of the keys.
+func NewFailoverTokenGenerator(keys []RegionKey, to string, from string, userType string, opts ...Option) TokenGenerator {
+	o := newOptions(opts)
+	failover := &FailoverEncrypter{
+		Keys:     make([]RegionKey, len(keys)),
+		Cooldown: DefaultFailoverCooldown,
+		Timeout:  DefaultRegionTimeout,
+	}
+	if o.failoverCooldown != 0 {
+		failover.Cooldown = o.failoverCooldown
+	}
+	if o.regionTimeout != 0 {
+		failover.Timeout = o.regionTimeout
+	}
+	for i, key := range keys {
+		if key.Encrypter == nil && o.newKMS != nil {
+			key.Encrypter = o.newKMS(key.Region)
+		}
+		if key.Encrypter == nil {
+			key.Encrypter = kmsv1.New(kmsv1.Config{}.NewClient(key.Region))
+		}
+		failover.Keys[i] = key
+	}
+	var keyID string
+	if len(keys) != 0 {
+		keyID = keys[0].KeyID
+	}
+	return TokenGenerator{
+		KeyID: keyID,
+		Context: map[string]*string{
+			"from":      aws.String(from),
+			"to":        aws.String(to),
+			"user_type": aws.String(userType),
+		},
+		Encrypter:        failover,
+		TokenLifetime:    DefaultTokenLifetime,
+		MaxTokenLifetime: DefaultMaxTokenLifetime,
+		RefreshMargin:    DefaultRefreshMargin,
+		TokenVersion:     DefaultTokenVersion,
+	}
+}
+
+// Encrypt encrypts plaintext with the key of the first healthy region.
+// If every region fails, the error lists each region's error and wraps the last one.
+func (f *FailoverEncrypter) Encrypt(ctx context.Context, keyID string, plaintext []byte, encryptionContext map[string]string) ([]byte, error) {
+	if len(f.Keys) == 0 {
+		return nil, errors.New("No KMS regions are configured")
+	}
+	var messages []string
+	var lastErr error
+	for _, key := range f.order() {
+		ciphertext, err := f.encrypt(ctx, key, plaintext, encryptionContext)
+		if err == nil {
+			f.markHealthy(key.Region)
+			return ciphertext, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		f.markFailed(key.Region)
+		messages = append(messages, fmt.Sprintf("%s: %s", key.Region, err))
+		lastErr = err
+	}
+	return nil, fmt.Errorf("KMS failed in every region (%s): %w", strings.Join(messages, "; "), lastErr)
+}
+
+func (f *FailoverEncrypter) encrypt(ctx context.Context, key RegionKey, plaintext []byte, encryptionContext map[string]string) ([]byte, error) {
+	timeout := f.Timeout
+	if timeout == 0 {
+		timeout = DefaultRegionTimeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if key.Encrypter == nil {
+		return nil, fmt.Errorf("No Encrypter is set for %s", key.Region)
+	}
+	return key.Encrypter.Encrypt(ctx, key.KeyID, plaintext, encryptionContext)
+}
+
+// order returns the healthy keys in order, followed by the keys still in their cooldown,
+// so that a region is tried again when all of them have failed recently.
+func (f *FailoverEncrypter) order() []RegionKey {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	healthy := make([]RegionKey, 0, len(f.Keys))
+	var cooling []RegionKey
+	for _, key := range f.Keys {
+		if f.cooling(key.Region) {
+			cooling = append(cooling, key)
+		} else {
+			healthy = append(healthy, key)
+		}
+	}
+	return append(healthy, cooling...)
+}
+
+// Healthy reports whether region has not failed within the cooldown.
+func (f *FailoverEncrypter) Healthy(region string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return !f.cooling(region)
+}
+
+// cooling reports whether region failed within the cooldown. f.mu must be held.
+func (f *FailoverEncrypter) cooling(region string) bool {
+	failedAt, ok := f.failedAt[region]
+	if !ok {
+		return false
+	}
+	cooldown := f.Cooldown
+	if cooldown == 0 {
+		cooldown = DefaultFailoverCooldown
+	}
+	return f.clock().Sub(failedAt) < cooldown
+}
+
+func (f *FailoverEncrypter) markFailed(region string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failedAt == nil {
+		f.failedAt = make(map[string]time.Time)
+	}
+	f.failedAt[region] = f.clock()
+}
+
+func (f *FailoverEncrypter) markHealthy(region string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.failedAt, region)
+}
+
+func (f *FailoverEncrypter) clock() time.Time {
+	if f.now != nil {
+		return f.now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,208 @@
+package kmsauth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stripe/go-confidant-client/kmsauth/kmstest"
+	"github.com/stripe/go-confidant-client/kmsauth/kmsv1"
+)
+
+// regionEncrypter fails while err is set, or blocks until the context is done while hang is set.
+type regionEncrypter struct {
+	mu    sync.Mutex
+	err   error
+	hang  bool
+	calls int
+	keyID string
+}
+
+func (e *regionEncrypter) Encrypt(ctx context.Context, keyID string, plaintext []byte, encryptionContext map[string]string) ([]byte, error) {
+	e.mu.Lock()
+	e.calls++
+	e.keyID = keyID
+	err, hang := e.err, e.hang
+	e.mu.Unlock()
+	if hang {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []byte(keyID), nil
+}
+
+func (e *regionEncrypter) set(err error, hang bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.err, e.hang, e.calls = err, hang, 0
+}
+
+func TestFailoverEncrypter(t *testing.T) {
+	east, west := &regionEncrypter{}, &regionEncrypter{}
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	f := &FailoverEncrypter{
+		Keys: []RegionKey{
+			{Region: "us-east-1", KeyID: "alias/east", Encrypter: east},
+			{Region: "us-west-2", KeyID: "alias/west", Encrypter: west},
+		},
+		Cooldown: time.Minute,
+		now:      func() time.Time { return now },
+	}
+	encrypt := func() string {
+		ciphertext, err := f.Encrypt(context.Background(), "ignored", []byte("plaintext"), nil)
+		if err != nil {
+			t.Fatalf("Could not encrypt: %s", err)
+		}
+		return string(ciphertext)
+	}
+
+	if key := encrypt(); key != "alias/east" {
+		t.Errorf("Expected the first region's key, got %s", key)
+	}
+
+	east.set(errors.New("InternalException"), false)
+	if key := encrypt(); key != "alias/west" {
+		t.Errorf("Expected to fail over to the second region, got %s", key)
+	}
+	if f.Healthy("us-east-1") {
+		t.Errorf("Expected us-east-1 to be unhealthy")
+	}
+	east.set(nil, false)
+	if key := encrypt(); key != "alias/west" || east.calls != 0 {
+		t.Errorf("Expected us-east-1 to be skipped during its cooldown, got %s after %d calls", key, east.calls)
+	}
+
+	now = now.Add(time.Minute)
+	if key := encrypt(); key != "alias/east" {
+		t.Errorf("Expected us-east-1 to be used after its cooldown, got %s", key)
+	}
+	if !f.Healthy("us-east-1") {
+		t.Errorf("Expected us-east-1 to be healthy")
+	}
+}
+
+func TestFailoverEncrypterAllRegionsFailing(t *testing.T) {
+	east, west := &regionEncrypter{}, &regionEncrypter{}
+	f := &FailoverEncrypter{
+		Keys: []RegionKey{
+			{Region: "us-east-1", KeyID: "alias/east", Encrypter: east},
+			{Region: "us-west-2", KeyID: "alias/west", Encrypter: west},
+		},
+	}
+	kmsErr := errors.New("AccessDeniedException")
+	east.set(kmsErr, false)
+	west.set(kmsErr, false)
+	_, err := f.Encrypt(context.Background(), "ignored", []byte("plaintext"), nil)
+	if !errors.Is(err, kmsErr) || !strings.Contains(err.Error(), "us-east-1") || !strings.Contains(err.Error(), "us-west-2") {
+		t.Errorf("Expected an error for both regions, got %v", err)
+	}
+
+	// Regions in their cooldown are still tried when no region is healthy.
+	west.set(nil, false)
+	ciphertext, err := f.Encrypt(context.Background(), "ignored", []byte("plaintext"), nil)
+	if err != nil || string(ciphertext) != "alias/west" {
+		t.Errorf("Expected to encrypt in us-west-2, got %q and %v", ciphertext, err)
+	}
+}
+
+func TestFailoverEncrypterTimeout(t *testing.T) {
+	east, west := &regionEncrypter{}, &regionEncrypter{}
+	east.set(nil, true)
+	f := &FailoverEncrypter{
+		Keys: []RegionKey{
+			{Region: "us-east-1", KeyID: "alias/east", Encrypter: east},
+			{Region: "us-west-2", KeyID: "alias/west", Encrypter: west},
+		},
+		Timeout: 10 * time.Millisecond,
+	}
+	ciphertext, err := f.Encrypt(context.Background(), "ignored", []byte("plaintext"), nil)
+	if err != nil || string(ciphertext) != "alias/west" {
+		t.Errorf("Expected to fail over after the timeout, got %q and %v", ciphertext, err)
+	}
+
+	// A canceled caller's context is returned rather than failing over.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	west.set(nil, true)
+	if _, err := f.Encrypt(ctx, "ignored", []byte("plaintext"), nil); err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestFailoverEncrypterDefaultTimeout(t *testing.T) {
+	east, west := kmstest.New(), kmstest.New()
+	east.AddKey("alias/authnz")
+	west.AddKey("alias/authnz")
+	east.Latency = time.Hour
+	generator := NewFailoverTokenGenerator([]RegionKey{
+		{Region: "us-east-1", KeyID: "alias/authnz", Encrypter: kmsv1.New(east)},
+		{Region: "us-west-2", KeyID: "alias/authnz", Encrypter: kmsv1.New(west)},
+	}, "confidant", "someone", "user")
+	if timeout := generator.Encrypter.(*FailoverEncrypter).Timeout; timeout != DefaultRegionTimeout {
+		t.Errorf("Expected the default region timeout, got %s", timeout)
+	}
+
+	start := time.Now()
+	if _, err := generator.GetToken(); err != nil {
+		t.Fatalf("Could not get token: %s", err)
+	}
+	if elapsed := time.Since(start); elapsed > DefaultRegionTimeout+time.Second {
+		t.Errorf("Expected to fail over after %s, took %s", DefaultRegionTimeout, elapsed)
+	}
+	if west.Calls(kmstest.OperationEncrypt) != 1 {
+		t.Errorf("Expected the token to be encrypted in us-west-2")
+	}
+}
+
+func TestNewFailoverTokenGenerator(t *testing.T) {
+	east, west := &regionEncrypter{}, &regionEncrypter{}
+	east.set(errors.New("InternalException"), false)
+	generator := NewFailoverTokenGenerator([]RegionKey{
+		{Region: "us-east-1", KeyID: "alias/east", Encrypter: east},
+		{Region: "us-west-2", KeyID: "alias/west", Encrypter: west},
+	}, "confidant", "someone", "user", WithFailoverCooldown(time.Hour), WithRegionTimeout(time.Second))
+	if generator.KeyID != "alias/east" {
+		t.Errorf("Expected the first region's key ID, got %s", generator.KeyID)
+	}
+	failover := generator.Encrypter.(*FailoverEncrypter)
+	if failover.Cooldown != time.Hour || failover.Timeout != time.Second {
+		t.Errorf("Expected a cooldown of 1h and timeout of 1s, got %s and %s", failover.Cooldown, failover.Timeout)
+	}
+	if _, err := generator.GetToken(); err != nil {
+		t.Fatalf("Could not get token: %s", err)
+	}
+	if west.keyID != "alias/west" {
+		t.Errorf("Expected the token to be encrypted with alias/west, got %s", west.keyID)
+	}
+}
+
+func TestNewFailoverTokenGeneratorFactory(t *testing.T) {
+	keys := []RegionKey{
+		{Region: "us-east-1", KeyID: "alias/east"},
+		{Region: "us-west-2", KeyID: "alias/west"},
+	}
+	generator := NewFailoverTokenGenerator(keys, "confidant", "someone", "user")
+	for _, key := range generator.Encrypter.(*FailoverEncrypter).Keys {
+		if _, ok := key.Encrypter.(*kmsv1.KMS); !ok {
+			t.Errorf("Expected an SDK v1 KMS client for %s, got %T", key.Region, key.Encrypter)
+		}
+	}
+
+	var regions []string
+	generator = NewFailoverTokenGenerator(keys, "confidant", "someone", "user", WithKMSFactory(func(region string) KMS {
+		regions = append(regions, region)
+		return fakeEncrypter{}
+	}))
+	if _, err := generator.GetToken(); err != nil {
+		t.Fatalf("Could not get token: %s", err)
+	}
+	if len(regions) != 2 || regions[0] != "us-east-1" || regions[1] != "us-west-2" {
+		t.Errorf("Expected a KMS client for each region, got %v", regions)
+	}
+}
@@ -0,0 +1,60 @@
+package kmsauth
+
+import (
+	"context"
+	"errors"
+
+	"github.com/stripe/go-confidant-client/kmsauth/kmsv1"
+)
+
+// Encrypter encrypts plaintext with a KMS key under an encryption context.
+// It is all a TokenGenerator needs from KMS, so any KMS client can be used through an adapter:
+// kmsv1.New for an AWS SDK v1 kmsiface.KMSAPI, or kmsv2.New for an SDK v2 *kms.Client.
+type Encrypter interface {
+	Encrypt(ctx context.Context, keyID string, plaintext []byte, encryptionContext map[string]string) ([]byte, error)
+}
+
+// Decrypter decrypts ciphertext encrypted under an encryption context, and returns
+// the plaintext and the ARN of the key it was encrypted with. If keyID is not empty,
+// ciphertext encrypted with any other key is not decrypted, and KMS returns an
+// IncorrectKeyException. It is all a TokenValidator needs from KMS.
+type Decrypter interface {
+	Decrypt(ctx context.Context, keyID string, ciphertext []byte, encryptionContext map[string]string) (plaintext []byte, keyARN string, err error)
+}
+
+// DataKeyGenerator generates a data key of size bytes under the KMS key keyID and encryptionContext,
+// and returns its plaintext and the ciphertext KMS decrypts it from. The kmsv1 and kmsv2 adapters
+// implement it, for encrypting data client-side such as Confidant blind credentials.
+type DataKeyGenerator interface {
+	GenerateDataKey(ctx context.Context, keyID string, size int, encryptionContext map[string]string) (plaintext, ciphertext []byte, err error)
+}
+
+// KMS is a KMS client that can both encrypt and decrypt, as the kmsv1 and kmsv2 adapters can.
+type KMS interface {
+	Encrypter
+	Decrypter
+}
+
+var errNoKMSClient = errors.New("No Encrypter, Decrypter or KMSClient is set")
+
+// encrypter returns the Encrypter, or an adapter for the SDK v1 KMSClient.
+func (g *TokenGenerator) encrypter() (Encrypter, error) {
+	if g.Encrypter != nil {
+		return g.Encrypter, nil
+	}
+	if g.KMSClient == nil {
+		return nil, errNoKMSClient
+	}
+	return kmsv1.New(g.KMSClient), nil
+}
+
+// decrypter returns the Decrypter, or an adapter for the SDK v1 KMSClient.
+func (v *TokenValidator) decrypter() (Decrypter, error) {
+	if v.Decrypter != nil {
+		return v.Decrypter, nil
+	}
+	if v.KMSClient == nil {
+		return nil, errNoKMSClient
+	}
+	return kmsv1.New(v.KMSClient), nil
+}
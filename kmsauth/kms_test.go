@@ -0,0 +1,105 @@
+package kmsauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/stripe/go-confidant-client/kmsauth/kmsv1"
+	"github.com/stripe/go-confidant-client/kmsauth/kmsv2"
+)
+
+const testKeyARN = "arn:aws:kms:us-east-1:123456789012:key/test"
+
+var (
+	_ KMS = &kmsv1.KMS{}
+	_ KMS = &kmsv2.KMS{}
+)
+
+// fakeEncrypter is an Encrypter and Decrypter that wraps the plaintext with its encryption context,
+// and only decrypts it with the same context.
+type fakeEncrypter struct{}
+
+type fakeEncrypterCiphertext struct {
+	KeyID     string
+	Context   map[string]string
+	Plaintext []byte
+}
+
+func (fakeEncrypter) Encrypt(ctx context.Context, keyID string, plaintext []byte, encryptionContext map[string]string) ([]byte, error) {
+	return json.Marshal(fakeEncrypterCiphertext{KeyID: keyID, Context: encryptionContext, Plaintext: plaintext})
+}
+
+func (fakeEncrypter) Decrypt(ctx context.Context, keyID string, ciphertext []byte, encryptionContext map[string]string) ([]byte, string, error) {
+	var c fakeEncrypterCiphertext
+	if err := json.Unmarshal(ciphertext, &c); err != nil {
+		return nil, "", err
+	}
+	if !reflect.DeepEqual(c.Context, encryptionContext) {
+		return nil, "", errors.New("InvalidCiphertextException")
+	}
+	if keyID != "" && keyID != c.KeyID {
+		return nil, "", awserr.New(kms.ErrCodeIncorrectKeyException, "The key ID in the request does not identify the key used to encrypt the ciphertext", nil)
+	}
+	return c.Plaintext, c.KeyID, nil
+}
+
+func TestEncrypterAndDecrypter(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	generator := NewTokenGenerator(testKeyARN, "confidant-production", "terraform-provider-confidant", "service", "us-east-1", WithEncrypter(fakeEncrypter{}))
+	if generator.Encrypter != (fakeEncrypter{}) {
+		t.Errorf("Expected the given Encrypter, got %T", generator.Encrypter)
+	}
+	generator.now = func() time.Time { return now }
+	token, err := generator.GetToken()
+	if err != nil {
+		t.Fatalf("Could not get token: %s", err)
+	}
+
+	validator := NewTokenValidator(testKeyARN, "confidant-production", "us-east-1", WithDecrypter(fakeEncrypter{}))
+	if validator.Decrypter != (fakeEncrypter{}) {
+		t.Errorf("Expected the given Decrypter, got %T", validator.Decrypter)
+	}
+	validator.now = func() time.Time { return now }
+	principal, err := validator.ValidateToken(generator.GetUsername(), token)
+	if err != nil {
+		t.Fatalf("Could not validate token: %s", err)
+	}
+	expected := Principal{Username: "terraform-provider-confidant", UserType: "service", TokenVersion: 2}
+	if *principal != expected {
+		t.Errorf("Expected %+v, got %+v", expected, *principal)
+	}
+}
+
+func TestDefaultKMSClient(t *testing.T) {
+	generator := NewTokenGenerator("key", "confidant", "someone", "user", "us-west-2")
+	if client, ok := generator.KMSClient.(*kms.KMS); !ok || aws.StringValue(client.Config.Region) != "us-west-2" {
+		t.Errorf("Expected an SDK v1 KMS client for us-west-2, got %T", generator.KMSClient)
+	}
+	validator := NewTokenValidator("key", "confidant", "us-west-2")
+	if client, ok := validator.KMSClient.(*kms.KMS); !ok || aws.StringValue(client.Config.Region) != "us-west-2" {
+		t.Errorf("Expected an SDK v1 KMS client for us-west-2, got %T", validator.KMSClient)
+	}
+
+	generator = NewTokenGenerator("key", "confidant", "someone", "user", "us-west-2", WithEncrypter(fakeEncrypter{}))
+	if generator.KMSClient != nil {
+		t.Errorf("Expected no SDK v1 KMS client with an Encrypter, got %T", generator.KMSClient)
+	}
+}
+
+func TestNoKMSClient(t *testing.T) {
+	generator := TokenGenerator{KeyID: "key"}
+	if _, err := generator.GetToken(); !errors.Is(err, errNoKMSClient) {
+		t.Errorf("Expected an error without an Encrypter or KMSClient, got %v", err)
+	}
+	validator := TokenValidator{To: "confidant", KeyIDs: []string{"key"}}
+	if _, err := validator.ValidateToken("2/user/someone", "dG9rZW4="); !errors.Is(err, errNoKMSClient) {
+		t.Errorf("Expected an error without a Decrypter or KMSClient, got %v", err)
+	}
+}
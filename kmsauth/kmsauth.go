@@ -1,77 +1,300 @@
 package kmsauth
 
 import (
-	"fmt"
-	"time"
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/kms"
 	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
 )
 
+// TimeFormat is the format of the not_before and not_after token fields.
+const TimeFormat = "20060102T150405Z"
+
+const (
+	// DefaultTokenLifetime is how long generated tokens are valid for.
+	DefaultTokenLifetime = 60 * time.Minute
+	// DefaultMaxTokenLifetime matches Confidant's default AUTH_TOKEN_MAX_LIFETIME.
+	DefaultMaxTokenLifetime = 60 * time.Minute
+	// DefaultRefreshMargin is how long before its expiry a cached token is replaced.
+	DefaultRefreshMargin = 5 * time.Minute
+	// DefaultTokenVersion is the token version generated when none is set.
+	DefaultTokenVersion = 2
+	// MinTokenVersion and MaxTokenVersion are the supported token versions.
+	MinTokenVersion = 1
+	MaxTokenVersion = 3
+)
+
 type TokenGenerator struct {
-	KeyID	string
+	KeyID   string
 	Context map[string]*string
+	// KMSClient is the AWS SDK v1 KMS client tokens are encrypted with when Encrypter is nil.
 	KMSClient kmsiface.KMSAPI
+	// Encrypter encrypts tokens with KMS, such as an SDK v1 client adapted by kmsv1.New
+	// or an SDK v2 client adapted by kmsv2.New. If set, it is used instead of KMSClient.
+	Encrypter Encrypter
+	// TokenLifetime is the time between now and not_after.
+	// Zero means DefaultTokenLifetime.
+	TokenLifetime time.Duration
+	// ClockSkew backdates not_before, so that tokens are accepted
+	// by servers whose clocks are behind ours.
+	ClockSkew time.Duration
+	// MaxTokenLifetime is the longest window between not_before and not_after
+	// the server accepts. Zero means DefaultMaxTokenLifetime.
+	MaxTokenLifetime time.Duration
+	// RefreshMargin is how long before not_after a cached token is regenerated.
+	// Zero means DefaultRefreshMargin; a negative value disables caching.
+	// A margin at least as long as the token lifetime is reduced to half the lifetime,
+	// so that tokens are still reused.
+	RefreshMargin time.Duration
+	// TokenVersion is the kmsauth token version (1, 2 or 3).
+	// Version 1 tokens use the bare "from" as username and omit user_type
+	// from the encryption context. Zero means DefaultTokenVersion.
+	TokenVersion int
+
+	mu         sync.Mutex
+	token      string
+	tokenKey   string
+	notAfter   time.Time
+	generating chan struct{}
+	now        func() time.Time
 }
 
 type Payload struct {
 	NotBefore string `json:"not_before"`
-	NotAfter string `json:"not_after"`
+	NotAfter  string `json:"not_after"`
 }
 
-func NewTokenGenerator(keyID, to string, from string, userType string, region string) TokenGenerator {
+// NewTokenGenerator returns a TokenGenerator for tokens from "from" to "to",
+// encrypted with the KMS key keyID in region.
+// Its Encrypter is the one given by WithEncrypter, or the one WithKMSFactory creates for region.
+// Without either, its KMSClient is the one given by WithKMSClient, or an SDK v1 client
+// for region created from session.New().
+func NewTokenGenerator(keyID, to string, from string, userType string, region string, opts ...Option) TokenGenerator {
 	context := map[string]*string{
-		"from": aws.String(from),
-		"to":  aws.String(to),
+		"from":      aws.String(from),
+		"to":        aws.String(to),
 		"user_type": aws.String(userType),
 	}
-	config := &aws.Config{Region: aws.String(region)}
-	client := kms.New(session.New(), config)
+	o := newOptions(opts)
+	encrypter, kmsClient := o.encrypterFor(region)
 	return TokenGenerator{
-		KeyID: keyID,
-		Context: context,
-		KMSClient: client,
+		KeyID:            keyID,
+		Context:          context,
+		KMSClient:        kmsClient,
+		Encrypter:        encrypter,
+		TokenLifetime:    DefaultTokenLifetime,
+		MaxTokenLifetime: DefaultMaxTokenLifetime,
+		RefreshMargin:    DefaultRefreshMargin,
+		TokenVersion:     DefaultTokenVersion,
 	}
 }
 
+// GetUsername returns the username to send alongside the token.
+// Version 1 usernames are the bare "from", later versions are "version/user_type/from".
 func (g *TokenGenerator) GetUsername() string {
 	userType := aws.StringValue(g.Context["user_type"])
 	from := aws.StringValue(g.Context["from"])
-	return fmt.Sprintf("%d/%s/%s", 2, userType, from)
+	version := g.version()
+	if version == 1 {
+		return from
+	}
+	return fmt.Sprintf("%d/%s/%s", version, userType, from)
 }
 
+// GetToken returns a base64 encoded KMS auth token.
+// Tokens are cached and reused until they are within RefreshMargin of expiring,
+// so it is cheap to call GetToken for every request. Changing KeyID, Context,
+// TokenLifetime, ClockSkew or TokenVersion discards the cached token.
+// It is safe to call GetToken from multiple goroutines. While a token is being generated,
+// other callers get the cached token if it has not expired, or wait for the new one.
 func (g *TokenGenerator) GetToken() (string, error) {
-	now := time.Now().UTC()
-	format := "20060102T150405Z"
-	start := now.Format(format)
-	end := now.Add(time.Minute * 60).Format(format)
+	return g.GetTokenWithContext(context.Background())
+}
+
+// GetTokenWithContext is like GetToken, but takes a context for the KMS call.
+func (g *TokenGenerator) GetTokenWithContext(ctx context.Context) (string, error) {
+	margin := g.refreshMargin()
+	if margin < 0 {
+		token, _, err := g.newToken(ctx, g.clock().UTC())
+		return token, err
+	}
+	key := g.cacheKey()
+	for {
+		now := g.clock().UTC()
+		g.mu.Lock()
+		if g.token != "" && g.tokenKey == key && now.Add(margin).Before(g.notAfter) {
+			token := g.token
+			g.mu.Unlock()
+			return token, nil
+		}
+		if wait := g.generating; wait != nil {
+			// While a new token is generated, one that has not expired yet is still good.
+			if g.token != "" && g.tokenKey == key && now.Before(g.notAfter) {
+				token := g.token
+				g.mu.Unlock()
+				return token, nil
+			}
+			g.mu.Unlock()
+			select {
+			case <-wait:
+				continue
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+		}
+		done := make(chan struct{})
+		g.generating = done
+		g.mu.Unlock()
+
+		token, notAfter, err := g.newToken(ctx, now)
+		g.mu.Lock()
+		g.generating = nil
+		if err == nil {
+			g.token = token
+			g.tokenKey = key
+			g.notAfter = notAfter
+		}
+		g.mu.Unlock()
+		close(done)
+		return token, err
+	}
+}
+
+// refreshMargin returns how long before not_after a cached token is regenerated.
+func (g *TokenGenerator) refreshMargin() time.Duration {
+	margin := g.RefreshMargin
+	if margin == 0 {
+		margin = DefaultRefreshMargin
+	}
+	// Otherwise every token would be too close to expiring to be reused.
+	if lifetime, err := g.lifetime(); err == nil && margin >= lifetime {
+		margin = lifetime / 2
+	}
+	return margin
+}
+
+// cacheKey identifies the settings a token was generated with, so that a cached token
+// is not reused after they change.
+func (g *TokenGenerator) cacheKey() string {
+	encryptionContext := g.encryptionContext()
+	keys := make([]string, 0, len(encryptionContext))
+	for k := range encryptionContext {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\x00%d\x00%s\x00%s", g.KeyID, g.version(), g.TokenLifetime, g.ClockSkew)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "\x00%s=%s", k, aws.StringValue(encryptionContext[k]))
+	}
+	return b.String()
+}
+
+// InvalidateToken discards the cached token if it is token, so that the next GetToken
+// generates a new one. Use it when a server rejects a token before it expires.
+// Only the rejected token is discarded, so that a newer one cached by another goroutine is kept.
+func (g *TokenGenerator) InvalidateToken(token string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.token == token {
+		g.token = ""
+		g.notAfter = time.Time{}
+	}
+}
+
+// newToken encrypts a payload that is valid from now - ClockSkew until now + TokenLifetime.
+// It returns the encoded token and the time at which it expires.
+func (g *TokenGenerator) newToken(ctx context.Context, now time.Time) (string, time.Time, error) {
+	if version := g.version(); version < MinTokenVersion || version > MaxTokenVersion {
+		return "", time.Time{}, fmt.Errorf("Unsupported token version %d", version)
+	}
+	lifetime, err := g.lifetime()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	notBefore := now.Add(-g.ClockSkew)
+	notAfter := now.Add(lifetime)
 	plaintext, err := json.Marshal(Payload{
-		NotBefore: start,
-		NotAfter: end,
+		NotBefore: notBefore.Format(TimeFormat),
+		NotAfter:  notAfter.Format(TimeFormat),
 	})
 	if err != nil {
-		return "", err
+		return "", time.Time{}, err
 	}
-	encrypted, err := g.Encrypt(plaintext)
+	encrypted, err := g.EncryptWithContext(ctx, plaintext)
 	if err != nil {
-		return "", err
+		return "", time.Time{}, err
 	}
-	return base64.StdEncoding.EncodeToString(encrypted), nil
+	return base64.StdEncoding.EncodeToString(encrypted), notAfter, nil
 }
 
-func (g *TokenGenerator) Encrypt(plaintext []byte) ([]byte, error) {
-	input := &kms.EncryptInput{
-		Plaintext:         plaintext,
-		EncryptionContext: g.Context,
-		GrantTokens:       []*string{},
-		KeyId:             aws.String(g.KeyID),
+// lifetime returns the token lifetime, checking that the window
+// between not_before and not_after fits within MaxTokenLifetime.
+func (g *TokenGenerator) lifetime() (time.Duration, error) {
+	lifetime := g.TokenLifetime
+	if lifetime == 0 {
+		lifetime = DefaultTokenLifetime
+	}
+	maxLifetime := g.MaxTokenLifetime
+	if maxLifetime == 0 {
+		maxLifetime = DefaultMaxTokenLifetime
+	}
+	if lifetime < 0 {
+		return 0, fmt.Errorf("Token lifetime must be positive, got %s", lifetime)
+	}
+	if g.ClockSkew < 0 {
+		return 0, fmt.Errorf("Clock skew must not be negative, got %s", g.ClockSkew)
+	}
+	if lifetime+g.ClockSkew > maxLifetime {
+		return 0, fmt.Errorf(
+			"Token lifetime %s plus clock skew %s exceeds the maximum lifetime of %s",
+			lifetime, g.ClockSkew, maxLifetime,
+		)
+	}
+	return lifetime, nil
+}
+
+func (g *TokenGenerator) version() int {
+	if g.TokenVersion == 0 {
+		return DefaultTokenVersion
 	}
-	resp, err := g.KMSClient.Encrypt(input)
+	return g.TokenVersion
+}
+
+// encryptionContext returns the KMS encryption context for the token version.
+func (g *TokenGenerator) encryptionContext() map[string]*string {
+	if g.version() != 1 {
+		return g.Context
+	}
+	return map[string]*string{
+		"from": g.Context["from"],
+		"to":   g.Context["to"],
+	}
+}
+
+func (g *TokenGenerator) clock() time.Time {
+	if g.now != nil {
+		return g.now()
+	}
+	return time.Now()
+}
+
+func (g *TokenGenerator) Encrypt(plaintext []byte) ([]byte, error) {
+	return g.EncryptWithContext(context.Background(), plaintext)
+}
+
+// EncryptWithContext is like Encrypt, but takes a context for the KMS call.
+func (g *TokenGenerator) EncryptWithContext(ctx context.Context, plaintext []byte) ([]byte, error) {
+	encrypter, err := g.encrypter()
 	if err != nil {
 		return []byte(""), err
 	}
-	return resp.CiphertextBlob, nil
+	return encrypter.Encrypt(ctx, g.KeyID, plaintext, aws.StringValueMap(g.encryptionContext()))
 }
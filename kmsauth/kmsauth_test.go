@@ -2,9 +2,14 @@ package kmsauth
 
 import (
 	"bytes"
-	"time"
-	"testing"
+	"context"
 	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/kms"
 	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
 )
@@ -18,6 +23,28 @@ func (m *mockKMSClient) Encrypt(input *kms.EncryptInput) (*kms.EncryptOutput, er
 	return &m.Resp, nil
 }
 
+type recordingKMSClient struct {
+	kmsiface.KMSAPI
+	Plaintext []byte
+	Context   map[string]*string
+}
+
+func (m *recordingKMSClient) Encrypt(input *kms.EncryptInput) (*kms.EncryptOutput, error) {
+	m.Plaintext = input.Plaintext
+	m.Context = input.EncryptionContext
+	return &kms.EncryptOutput{CiphertextBlob: input.Plaintext}, nil
+}
+
+type countingKMSClient struct {
+	kmsiface.KMSAPI
+	Calls int32
+}
+
+func (m *countingKMSClient) Encrypt(input *kms.EncryptInput) (*kms.EncryptOutput, error) {
+	atomic.AddInt32(&m.Calls, 1)
+	return &kms.EncryptOutput{CiphertextBlob: input.Plaintext}, nil
+}
+
 func TestGetUsername(t *testing.T) {
 	key := "alias/authnz-production"
 	to := "confidant-production"
@@ -35,6 +62,48 @@ func TestGetUsername(t *testing.T) {
 	}
 }
 
+func TestTokenVersions(t *testing.T) {
+	tests := []struct {
+		version     int
+		username    string
+		contextKeys []string
+	}{
+		{1, "terraform-provider-confidant", []string{"from", "to"}},
+		{2, "2/user/terraform-provider-confidant", []string{"from", "to", "user_type"}},
+		{3, "3/user/terraform-provider-confidant", []string{"from", "to", "user_type"}},
+	}
+	for _, test := range tests {
+		generator := NewTokenGenerator("key", "confidant-production", "terraform-provider-confidant", "user", "us-east-1")
+		client := &recordingKMSClient{}
+		generator.KMSClient = client
+		generator.TokenVersion = test.version
+		username := generator.GetUsername()
+		if username != test.username {
+			t.Errorf("Version %d: expected username %s, got %s", test.version, test.username, username)
+		}
+		if _, err := generator.GetToken(); err != nil {
+			t.Fatalf("Version %d: could not get token: %s", test.version, err)
+		}
+		if len(client.Context) != len(test.contextKeys) {
+			t.Errorf("Version %d: expected encryption context keys %v, got %v", test.version, test.contextKeys, client.Context)
+		}
+		for _, key := range test.contextKeys {
+			if client.Context[key] == nil {
+				t.Errorf("Version %d: encryption context is missing %s", test.version, key)
+			}
+		}
+	}
+}
+
+func TestUnsupportedTokenVersion(t *testing.T) {
+	generator := NewTokenGenerator("key", "confidant", "username", "user", "us-east-1")
+	generator.KMSClient = &countingKMSClient{}
+	generator.TokenVersion = 4
+	if _, err := generator.GetToken(); err == nil {
+		t.Errorf("Expected an error for token version 4")
+	}
+}
+
 func TestEncrypt(t *testing.T) {
 	key := "alias/authnz-production"
 	to := "confidant-production"
@@ -48,7 +117,7 @@ func TestEncrypt(t *testing.T) {
 	end := now.Add(time.Minute * 60).Format(format)
 	plaintext, err := json.Marshal(Payload{
 		NotBefore: start,
-		NotAfter: end,
+		NotAfter:  end,
 	})
 	if err != nil {
 		t.Errorf("Could not generate plaintext: %e", err)
@@ -67,3 +136,219 @@ func TestEncrypt(t *testing.T) {
 		t.Errorf("Encryption failed: expected %s as Ciphertextblob, got %s", expected.CiphertextBlob, ciphertext)
 	}
 }
+
+func TestGetTokenCache(t *testing.T) {
+	generator := NewTokenGenerator("key", "confidant", "username", "user", "us-east-1")
+	client := &countingKMSClient{}
+	generator.KMSClient = client
+	now := time.Date(2018, 7, 3, 17, 3, 1, 0, time.UTC)
+	generator.now = func() time.Time { return now }
+
+	first, err := generator.GetToken()
+	if err != nil {
+		t.Fatalf("Could not get token: %s", err)
+	}
+	now = now.Add(50 * time.Minute)
+	second, err := generator.GetToken()
+	if err != nil {
+		t.Fatalf("Could not get token: %s", err)
+	}
+	if first != second {
+		t.Errorf("Expected cached token %s, got %s", first, second)
+	}
+	if client.Calls != 1 {
+		t.Errorf("Expected 1 call to KMS, got %d", client.Calls)
+	}
+
+	// Within the refresh margin of not_after, a new token is generated.
+	now = now.Add(6 * time.Minute)
+	third, err := generator.GetToken()
+	if err != nil {
+		t.Fatalf("Could not get token: %s", err)
+	}
+	if third == first {
+		t.Errorf("Expected a new token once the cached token is close to expiry")
+	}
+	if client.Calls != 2 {
+		t.Errorf("Expected 2 calls to KMS, got %d", client.Calls)
+	}
+}
+
+func TestGetTokenCacheShortLifetime(t *testing.T) {
+	generator := NewTokenGenerator("key", "confidant", "username", "user", "us-east-1")
+	client := &countingKMSClient{}
+	generator.KMSClient = client
+	generator.TokenLifetime = DefaultRefreshMargin
+	now := time.Date(2018, 7, 3, 17, 3, 1, 0, time.UTC)
+	generator.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if _, err := generator.GetToken(); err != nil {
+			t.Fatalf("Could not get token: %s", err)
+		}
+		now = now.Add(time.Minute)
+	}
+	if client.Calls != 1 {
+		t.Errorf("Expected the token to be cached for half its lifetime, got %d calls to KMS", client.Calls)
+	}
+	if _, err := generator.GetToken(); err != nil {
+		t.Fatalf("Could not get token: %s", err)
+	}
+	if client.Calls != 2 {
+		t.Errorf("Expected a new token after half its lifetime, got %d calls to KMS", client.Calls)
+	}
+}
+
+func TestGetTokenCacheDisabled(t *testing.T) {
+	generator := NewTokenGenerator("key", "confidant", "username", "user", "us-east-1")
+	client := &countingKMSClient{}
+	generator.KMSClient = client
+	generator.RefreshMargin = -1
+	for i := 0; i < 3; i++ {
+		if _, err := generator.GetToken(); err != nil {
+			t.Fatalf("Could not get token: %s", err)
+		}
+	}
+	if client.Calls != 3 {
+		t.Errorf("Expected 3 calls to KMS, got %d", client.Calls)
+	}
+}
+
+func TestGetTokenConcurrent(t *testing.T) {
+	generator := NewTokenGenerator("key", "confidant", "username", "user", "us-east-1")
+	client := &countingKMSClient{}
+	generator.KMSClient = client
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := generator.GetToken(); err != nil {
+				t.Errorf("Could not get token: %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if client.Calls != 1 {
+		t.Errorf("Expected 1 call to KMS, got %d", client.Calls)
+	}
+}
+
+func TestGetTokenCacheSettings(t *testing.T) {
+	generator := NewTokenGenerator("key", "confidant", "username", "user", "us-east-1")
+	client := &countingKMSClient{}
+	generator.KMSClient = client
+	if _, err := generator.GetToken(); err != nil {
+		t.Fatalf("Could not get token: %s", err)
+	}
+
+	generator.Context["from"] = aws.String("someone-else")
+	if _, err := generator.GetToken(); err != nil {
+		t.Fatalf("Could not get token: %s", err)
+	}
+	if client.Calls != 2 {
+		t.Errorf("Expected a new token after changing the context, got %d calls", client.Calls)
+	}
+	for _, change := range []func(){
+		func() { generator.KeyID = "other-key" },
+		func() { generator.TokenVersion = 3 },
+		func() { generator.TokenLifetime = 30 * time.Minute },
+	} {
+		calls := client.Calls
+		change()
+		if _, err := generator.GetToken(); err != nil {
+			t.Fatalf("Could not get token: %s", err)
+		}
+		if client.Calls != calls+1 {
+			t.Errorf("Expected a new token after changing the generator, got %d calls", client.Calls)
+		}
+	}
+}
+
+// blockingEncrypter blocks in Encrypt until release is closed.
+type blockingEncrypter struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (e *blockingEncrypter) Encrypt(ctx context.Context, keyID string, plaintext []byte, encryptionContext map[string]string) ([]byte, error) {
+	e.started <- struct{}{}
+	<-e.release
+	return plaintext, nil
+}
+
+func TestGetTokenDuringRefresh(t *testing.T) {
+	generator := NewTokenGenerator("key", "confidant", "username", "user", "us-east-1")
+	encrypter := &blockingEncrypter{started: make(chan struct{}, 1), release: make(chan struct{})}
+	generator.Encrypter = encrypter
+	now := time.Date(2018, 7, 3, 17, 3, 1, 0, time.UTC)
+	var nowMu sync.Mutex
+	generator.now = func() time.Time {
+		nowMu.Lock()
+		defer nowMu.Unlock()
+		return now
+	}
+
+	close(encrypter.release)
+	first, err := generator.GetToken()
+	if err != nil {
+		t.Fatalf("Could not get token: %s", err)
+	}
+	<-encrypter.started
+	encrypter.release = make(chan struct{})
+
+	// Within the refresh margin, one caller refreshes the token while others keep using it.
+	nowMu.Lock()
+	now = now.Add(56 * time.Minute)
+	nowMu.Unlock()
+	refreshed := make(chan string)
+	go func() {
+		token, _ := generator.GetToken()
+		refreshed <- token
+	}()
+	<-encrypter.started
+	token, err := generator.GetToken()
+	if err != nil || token != first {
+		t.Errorf("Expected the cached token during the refresh, got %v", err)
+	}
+	close(encrypter.release)
+	if token := <-refreshed; token == first {
+		t.Errorf("Expected a new token from the refresh")
+	}
+}
+
+func TestGetTokenLifetime(t *testing.T) {
+	generator := NewTokenGenerator("key", "confidant", "username", "user", "us-east-1")
+	client := &recordingKMSClient{}
+	generator.KMSClient = client
+	now := time.Date(2018, 7, 3, 17, 3, 1, 0, time.UTC)
+	generator.now = func() time.Time { return now }
+	generator.TokenLifetime = 10 * time.Minute
+	generator.ClockSkew = 3 * time.Minute
+	generator.MaxTokenLifetime = 15 * time.Minute
+	if _, err := generator.GetToken(); err != nil {
+		t.Fatalf("Could not get token: %s", err)
+	}
+	var payload Payload
+	if err := json.Unmarshal(client.Plaintext, &payload); err != nil {
+		t.Fatalf("Could not unmarshal payload: %s", err)
+	}
+	expected := Payload{NotBefore: "20180703T170001Z", NotAfter: "20180703T171301Z"}
+	if payload != expected {
+		t.Errorf("Expected payload %+v, got %+v", expected, payload)
+	}
+}
+
+func TestGetTokenLifetimeTooLong(t *testing.T) {
+	generator := NewTokenGenerator("key", "confidant", "username", "user", "us-east-1")
+	generator.KMSClient = &countingKMSClient{}
+	generator.TokenLifetime = 60 * time.Minute
+	generator.ClockSkew = time.Minute
+	if _, err := generator.GetToken(); err == nil {
+		t.Errorf("Expected an error when lifetime plus skew exceeds the maximum lifetime")
+	}
+	generator.MaxTokenLifetime = 61 * time.Minute
+	if _, err := generator.GetToken(); err != nil {
+		t.Errorf("Could not get token: %s", err)
+	}
+}
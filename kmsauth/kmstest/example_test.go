@@ -0,0 +1,29 @@
+package kmstest_test
+
+import (
+	"fmt"
+
+	"github.com/stripe/go-confidant-client/kmsauth"
+	"github.com/stripe/go-confidant-client/kmsauth/kmstest"
+	"github.com/stripe/go-confidant-client/kmsauth/kmsv1"
+)
+
+func Example() {
+	fake := kmstest.New()
+	fake.AddKey("alias/authnz")
+	generator := kmsauth.NewTokenGenerator("alias/authnz", "confidant", "someone", "user", "us-east-1", kmsauth.WithKMS(kmsv1.New(fake)))
+	validator := kmsauth.NewTokenValidator("alias/authnz", "confidant", "us-east-1", kmsauth.WithKMS(kmsv1.New(fake)))
+
+	token, err := generator.GetToken()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	principal, err := validator.ValidateToken(generator.GetUsername(), token)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(principal.Username, principal.UserType)
+	// Output: someone user
+}
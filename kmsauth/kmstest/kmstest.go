@@ -0,0 +1,336 @@
+// Package kmstest provides an in-memory KMS for testing code that generates and validates
+// kmsauth tokens, or encrypts blind credentials, without AWS.
+//
+//	fake := kmstest.New()
+//	fake.AddKey("alias/authnz")
+//	generator := kmsauth.NewTokenGenerator("alias/authnz", "confidant", "me", "user", "us-east-1",
+//		kmsauth.WithKMS(kmsv1.New(fake)))
+//	validator := kmsauth.NewTokenValidator("alias/authnz", "confidant", "us-east-1", kmsauth.WithKMS(kmsv1.New(fake)))
+package kmstest
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+)
+
+const (
+	// DefaultRegion and DefaultAccountID are used in key ARNs when KMS.Region and KMS.AccountID are empty.
+	DefaultRegion    = "us-east-1"
+	DefaultAccountID = "123456789012"
+)
+
+// Operations whose errors and calls are tracked.
+const (
+	OperationEncrypt         = "Encrypt"
+	OperationDecrypt         = "Decrypt"
+	OperationGenerateDataKey = "GenerateDataKey"
+)
+
+// KMS is an in-memory kmsiface.KMSAPI. It implements Encrypt, Decrypt and GenerateDataKey
+// with real AES-GCM encryption, bound to the encryption context like KMS's, so ciphertexts
+// only decrypt with the same context. Keys can be referred to by ID, ARN, alias or alias ARN.
+// Calling the other KMSAPI methods panics. It is safe to use from multiple goroutines.
+type KMS struct {
+	kmsiface.KMSAPI
+
+	// Region and AccountID are used in key ARNs. Empty means DefaultRegion and DefaultAccountID.
+	Region    string
+	AccountID string
+	// Latency delays every call, as long as its context isn't done.
+	Latency time.Duration
+
+	mu      sync.Mutex
+	keys    map[string][]byte
+	aliases map[string]string
+	errors  map[string]error
+	calls   map[string]int
+}
+
+// New returns a KMS without keys.
+func New() *KMS {
+	return &KMS{}
+}
+
+// AddKey creates a key with the given aliases, such as "alias/authnz", and returns its ARN.
+func (k *KMS) AddKey(aliases ...string) string {
+	material := make([]byte, 32)
+	if _, err := rand.Read(material); err != nil {
+		panic(err)
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+	keyID := fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:16])
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.keys == nil {
+		k.keys = make(map[string][]byte)
+		k.aliases = make(map[string]string)
+	}
+	k.keys[keyID] = material
+	for _, alias := range aliases {
+		k.aliases[alias] = keyID
+	}
+	return k.keyARN(keyID)
+}
+
+// SetError makes calls to operation, such as OperationEncrypt, return err until it is set to nil.
+func (k *KMS) SetError(operation string, err error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.errors == nil {
+		k.errors = make(map[string]error)
+	}
+	k.errors[operation] = err
+}
+
+// Calls returns how many times operation has been called, including calls that failed.
+func (k *KMS) Calls(operation string) int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.calls[operation]
+}
+
+// Encrypt encrypts the plaintext with the key, under the encryption context.
+func (k *KMS) Encrypt(input *kms.EncryptInput) (*kms.EncryptOutput, error) {
+	return k.EncryptWithContext(context.Background(), input)
+}
+
+func (k *KMS) EncryptWithContext(ctx aws.Context, input *kms.EncryptInput, opts ...request.Option) (*kms.EncryptOutput, error) {
+	if err := k.call(ctx, OperationEncrypt); err != nil {
+		return nil, err
+	}
+	keyID, err := k.resolve(aws.StringValue(input.KeyId))
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := k.encrypt(keyID, input.Plaintext, input.EncryptionContext)
+	if err != nil {
+		return nil, err
+	}
+	return &kms.EncryptOutput{CiphertextBlob: ciphertext, KeyId: aws.String(k.keyARN(keyID))}, nil
+}
+
+// Decrypt decrypts a ciphertext from Encrypt or GenerateDataKey, if the encryption context
+// is the same, and the KeyId, if set, refers to the key it was encrypted with.
+func (k *KMS) Decrypt(input *kms.DecryptInput) (*kms.DecryptOutput, error) {
+	return k.DecryptWithContext(context.Background(), input)
+}
+
+func (k *KMS) DecryptWithContext(ctx aws.Context, input *kms.DecryptInput, opts ...request.Option) (*kms.DecryptOutput, error) {
+	if err := k.call(ctx, OperationDecrypt); err != nil {
+		return nil, err
+	}
+	keyID, plaintext, err := k.decrypt(input.CiphertextBlob, input.EncryptionContext)
+	if err != nil {
+		return nil, err
+	}
+	if input.KeyId != nil {
+		expected, err := k.resolve(aws.StringValue(input.KeyId))
+		if err != nil {
+			return nil, err
+		}
+		if expected != keyID {
+			return nil, awserr.New(kms.ErrCodeIncorrectKeyException, "The key ID in the request does not identify the key used to encrypt the ciphertext", nil)
+		}
+	}
+	return &kms.DecryptOutput{Plaintext: plaintext, KeyId: aws.String(k.keyARN(keyID))}, nil
+}
+
+// GenerateDataKey returns a random data key of NumberOfBytes or KeySpec, and its ciphertext.
+func (k *KMS) GenerateDataKey(input *kms.GenerateDataKeyInput) (*kms.GenerateDataKeyOutput, error) {
+	return k.GenerateDataKeyWithContext(context.Background(), input)
+}
+
+func (k *KMS) GenerateDataKeyWithContext(ctx aws.Context, input *kms.GenerateDataKeyInput, opts ...request.Option) (*kms.GenerateDataKeyOutput, error) {
+	if err := k.call(ctx, OperationGenerateDataKey); err != nil {
+		return nil, err
+	}
+	keyID, err := k.resolve(aws.StringValue(input.KeyId))
+	if err != nil {
+		return nil, err
+	}
+	var size int64
+	switch {
+	case input.NumberOfBytes != nil && input.KeySpec != nil:
+		return nil, awserr.New("ValidationException", "Specify either KeySpec or NumberOfBytes, not both", nil)
+	case input.NumberOfBytes != nil:
+		size = aws.Int64Value(input.NumberOfBytes)
+	case aws.StringValue(input.KeySpec) == kms.DataKeySpecAes256:
+		size = 32
+	case aws.StringValue(input.KeySpec) == kms.DataKeySpecAes128:
+		size = 16
+	default:
+		return nil, awserr.New("ValidationException", "KeySpec or NumberOfBytes is required", nil)
+	}
+	if size < 1 || size > 1024 {
+		return nil, awserr.New("ValidationException", "NumberOfBytes must be between 1 and 1024", nil)
+	}
+	plaintext := make([]byte, size)
+	if _, err := rand.Read(plaintext); err != nil {
+		return nil, err
+	}
+	ciphertext, err := k.encrypt(keyID, plaintext, input.EncryptionContext)
+	if err != nil {
+		return nil, err
+	}
+	return &kms.GenerateDataKeyOutput{CiphertextBlob: ciphertext, Plaintext: plaintext, KeyId: aws.String(k.keyARN(keyID))}, nil
+}
+
+// call records a call to operation, waits for the latency and returns the error set for it.
+func (k *KMS) call(ctx context.Context, operation string) error {
+	k.mu.Lock()
+	if k.calls == nil {
+		k.calls = make(map[string]int)
+	}
+	k.calls[operation]++
+	err := k.errors[operation]
+	latency := k.Latency
+	k.mu.Unlock()
+	if latency > 0 {
+		timer := time.NewTimer(latency)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return awserr.New(request.CanceledErrorCode, "request context canceled", ctx.Err())
+		}
+	}
+	return err
+}
+
+// resolve returns the ID of the key a key ID, key ARN, alias or alias ARN refers to.
+func (k *KMS) resolve(ref string) (string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	name := ref
+	if strings.HasPrefix(ref, "arn:") {
+		prefix := fmt.Sprintf("arn:aws:kms:%s:%s:", k.region(), k.accountID())
+		if !strings.HasPrefix(ref, prefix) {
+			return "", awserr.New(kms.ErrCodeNotFoundException, fmt.Sprintf("Invalid arn %s", ref), nil)
+		}
+		name = strings.TrimPrefix(strings.TrimPrefix(ref, prefix), "key/")
+	}
+	if strings.HasPrefix(name, "alias/") {
+		keyID, ok := k.aliases[name]
+		if !ok {
+			return "", awserr.New(kms.ErrCodeNotFoundException, fmt.Sprintf("Alias %s is not found.", ref), nil)
+		}
+		return keyID, nil
+	}
+	if _, ok := k.keys[name]; !ok {
+		return "", awserr.New(kms.ErrCodeNotFoundException, fmt.Sprintf("Key '%s' does not exist", ref), nil)
+	}
+	return name, nil
+}
+
+// encrypt seals plaintext with the key, authenticating the encryption context.
+// Ciphertexts are the key ID's length and the key ID, followed by the nonce and sealed plaintext.
+func (k *KMS) encrypt(keyID string, plaintext []byte, encryptionContext map[string]*string) ([]byte, error) {
+	if len(plaintext) == 0 || len(plaintext) > 4096 {
+		return nil, awserr.New("ValidationException", "Plaintext must be between 1 and 4096 bytes", nil)
+	}
+	aead, err := k.aead(keyID)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, uint16(len(keyID)))
+	buf.WriteString(keyID)
+	buf.Write(nonce)
+	return aead.Seal(buf.Bytes(), nonce, plaintext, canonicalContext(encryptionContext)), nil
+}
+
+func (k *KMS) decrypt(ciphertext []byte, encryptionContext map[string]*string) (string, []byte, error) {
+	invalid := awserr.New(kms.ErrCodeInvalidCiphertextException, "", nil)
+	if len(ciphertext) < 2 {
+		return "", nil, invalid
+	}
+	n := int(binary.BigEndian.Uint16(ciphertext))
+	if len(ciphertext) < 2+n {
+		return "", nil, invalid
+	}
+	keyID := string(ciphertext[2 : 2+n])
+	aead, err := k.aead(keyID)
+	if err != nil {
+		return "", nil, invalid
+	}
+	rest := ciphertext[2+n:]
+	if len(rest) < aead.NonceSize() {
+		return "", nil, invalid
+	}
+	plaintext, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], canonicalContext(encryptionContext))
+	if err != nil {
+		return "", nil, invalid
+	}
+	return keyID, plaintext, nil
+}
+
+func (k *KMS) aead(keyID string) (cipher.AEAD, error) {
+	k.mu.Lock()
+	material, ok := k.keys[keyID]
+	k.mu.Unlock()
+	if !ok {
+		return nil, awserr.New(kms.ErrCodeNotFoundException, fmt.Sprintf("Key '%s' does not exist", keyID), nil)
+	}
+	block, err := aes.NewCipher(material)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (k *KMS) keyARN(keyID string) string {
+	return fmt.Sprintf("arn:aws:kms:%s:%s:key/%s", k.region(), k.accountID(), keyID)
+}
+
+func (k *KMS) region() string {
+	if k.Region != "" {
+		return k.Region
+	}
+	return DefaultRegion
+}
+
+func (k *KMS) accountID() string {
+	if k.AccountID != "" {
+		return k.AccountID
+	}
+	return DefaultAccountID
+}
+
+// canonicalContext encodes an encryption context independently of the order of its keys.
+func canonicalContext(encryptionContext map[string]*string) []byte {
+	keys := make([]string, 0, len(encryptionContext))
+	for key := range encryptionContext {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var buf bytes.Buffer
+	for _, key := range keys {
+		value := aws.StringValue(encryptionContext[key])
+		fmt.Fprintf(&buf, "%s=%s;", hex.EncodeToString([]byte(key)), hex.EncodeToString([]byte(value)))
+	}
+	return buf.Bytes()
+}
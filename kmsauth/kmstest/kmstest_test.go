@@ -0,0 +1,163 @@
+package kmstest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/kms"
+)
+
+func errorCode(err error) string {
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		return awsErr.Code()
+	}
+	return ""
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	fake := New()
+	arn := fake.AddKey("alias/authnz")
+	encryptionContext := aws.StringMap(map[string]string{"from": "someone", "to": "confidant"})
+
+	for _, keyID := range []string{"alias/authnz", arn, strings.TrimPrefix(arn, "arn:aws:kms:us-east-1:123456789012:key/"), "arn:aws:kms:us-east-1:123456789012:alias/authnz"} {
+		encrypted, err := fake.Encrypt(&kms.EncryptInput{KeyId: aws.String(keyID), Plaintext: []byte("secret"), EncryptionContext: encryptionContext})
+		if err != nil {
+			t.Fatalf("%s: could not encrypt: %s", keyID, err)
+		}
+		if aws.StringValue(encrypted.KeyId) != arn {
+			t.Errorf("%s: expected key %s, got %s", keyID, arn, aws.StringValue(encrypted.KeyId))
+		}
+		if bytes.Contains(encrypted.CiphertextBlob, []byte("secret")) {
+			t.Errorf("%s: expected the plaintext to be encrypted", keyID)
+		}
+		decrypted, err := fake.Decrypt(&kms.DecryptInput{CiphertextBlob: encrypted.CiphertextBlob, EncryptionContext: encryptionContext})
+		if err != nil {
+			t.Fatalf("%s: could not decrypt: %s", keyID, err)
+		}
+		if string(decrypted.Plaintext) != "secret" || aws.StringValue(decrypted.KeyId) != arn {
+			t.Errorf("%s: expected secret from %s, got %q from %s", keyID, arn, decrypted.Plaintext, aws.StringValue(decrypted.KeyId))
+		}
+	}
+}
+
+func TestEncryptionContext(t *testing.T) {
+	fake := New()
+	fake.AddKey("alias/authnz")
+	encrypted, err := fake.Encrypt(&kms.EncryptInput{
+		KeyId:             aws.String("alias/authnz"),
+		Plaintext:         []byte("secret"),
+		EncryptionContext: aws.StringMap(map[string]string{"from": "someone", "to": "confidant"}),
+	})
+	if err != nil {
+		t.Fatalf("Could not encrypt: %s", err)
+	}
+	tests := []map[string]string{
+		nil,
+		{"from": "someone"},
+		{"from": "someone-else", "to": "confidant"},
+		{"from": "someone", "to": "confidant", "user_type": "user"},
+	}
+	for _, encryptionContext := range tests {
+		_, err := fake.Decrypt(&kms.DecryptInput{CiphertextBlob: encrypted.CiphertextBlob, EncryptionContext: aws.StringMap(encryptionContext)})
+		if errorCode(err) != kms.ErrCodeInvalidCiphertextException {
+			t.Errorf("%v: expected InvalidCiphertextException, got %v", encryptionContext, err)
+		}
+	}
+	if _, err := fake.Decrypt(&kms.DecryptInput{CiphertextBlob: []byte("garbage")}); errorCode(err) != kms.ErrCodeInvalidCiphertextException {
+		t.Errorf("Expected InvalidCiphertextException for garbage, got %v", err)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	fake := New()
+	fake.AddKey("alias/a")
+	fake.AddKey("alias/b")
+	if _, err := fake.Encrypt(&kms.EncryptInput{KeyId: aws.String("alias/missing"), Plaintext: []byte("secret")}); errorCode(err) != kms.ErrCodeNotFoundException {
+		t.Errorf("Expected NotFoundException for a missing alias, got %v", err)
+	}
+	encrypted, err := fake.Encrypt(&kms.EncryptInput{KeyId: aws.String("alias/a"), Plaintext: []byte("secret")})
+	if err != nil {
+		t.Fatalf("Could not encrypt: %s", err)
+	}
+	if _, err := fake.Decrypt(&kms.DecryptInput{CiphertextBlob: encrypted.CiphertextBlob, KeyId: aws.String("alias/b")}); errorCode(err) != kms.ErrCodeIncorrectKeyException {
+		t.Errorf("Expected IncorrectKeyException, got %v", err)
+	}
+	if _, err := New().Decrypt(&kms.DecryptInput{CiphertextBlob: encrypted.CiphertextBlob}); errorCode(err) != kms.ErrCodeInvalidCiphertextException {
+		t.Errorf("Expected another KMS not to decrypt the ciphertext, got %v", err)
+	}
+}
+
+func TestGenerateDataKey(t *testing.T) {
+	fake := New()
+	fake.AddKey("alias/blind")
+	encryptionContext := aws.StringMap(map[string]string{"group": "web"})
+	dataKey, err := fake.GenerateDataKey(&kms.GenerateDataKeyInput{KeyId: aws.String("alias/blind"), NumberOfBytes: aws.Int64(32), EncryptionContext: encryptionContext})
+	if err != nil {
+		t.Fatalf("Could not generate data key: %s", err)
+	}
+	if len(dataKey.Plaintext) != 32 {
+		t.Errorf("Expected a 32 byte data key, got %d bytes", len(dataKey.Plaintext))
+	}
+	decrypted, err := fake.Decrypt(&kms.DecryptInput{CiphertextBlob: dataKey.CiphertextBlob, EncryptionContext: encryptionContext})
+	if err != nil {
+		t.Fatalf("Could not decrypt data key: %s", err)
+	}
+	if !bytes.Equal(decrypted.Plaintext, dataKey.Plaintext) {
+		t.Errorf("Expected the decrypted data key to match")
+	}
+	aes128, err := fake.GenerateDataKey(&kms.GenerateDataKeyInput{KeyId: aws.String("alias/blind"), KeySpec: aws.String(kms.DataKeySpecAes128)})
+	if err != nil || len(aes128.Plaintext) != 16 {
+		t.Errorf("Expected a 16 byte data key, got %v", err)
+	}
+	if _, err := fake.GenerateDataKey(&kms.GenerateDataKeyInput{KeyId: aws.String("alias/blind")}); errorCode(err) != "ValidationException" {
+		t.Errorf("Expected a ValidationException without a size, got %v", err)
+	}
+}
+
+func TestErrorsAndCalls(t *testing.T) {
+	fake := New()
+	fake.AddKey("alias/authnz")
+	input := &kms.EncryptInput{KeyId: aws.String("alias/authnz"), Plaintext: []byte("secret")}
+	throttled := awserr.New("ThrottlingException", "Rate exceeded", nil)
+	fake.SetError(OperationEncrypt, throttled)
+	if _, err := fake.Encrypt(input); err != throttled {
+		t.Errorf("Expected the injected error, got %v", err)
+	}
+	fake.SetError(OperationEncrypt, nil)
+	if _, err := fake.Encrypt(input); err != nil {
+		t.Errorf("Expected no error after clearing it, got %v", err)
+	}
+	if calls := fake.Calls(OperationEncrypt); calls != 2 {
+		t.Errorf("Expected 2 Encrypt calls, got %d", calls)
+	}
+	if calls := fake.Calls(OperationDecrypt); calls != 0 {
+		t.Errorf("Expected no Decrypt calls, got %d", calls)
+	}
+}
+
+func TestLatency(t *testing.T) {
+	fake := New()
+	fake.AddKey("alias/authnz")
+	fake.Latency = 20 * time.Millisecond
+	input := &kms.EncryptInput{KeyId: aws.String("alias/authnz"), Plaintext: []byte("secret")}
+	start := time.Now()
+	if _, err := fake.Encrypt(input); err != nil {
+		t.Fatalf("Could not encrypt: %s", err)
+	}
+	if elapsed := time.Since(start); elapsed < fake.Latency {
+		t.Errorf("Expected Encrypt to take at least %s, took %s", fake.Latency, elapsed)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if _, err := fake.EncryptWithContext(ctx, input); errorCode(err) != request.CanceledErrorCode {
+		t.Errorf("Expected the request to be canceled, got %v", err)
+	}
+}
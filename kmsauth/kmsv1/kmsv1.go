@@ -0,0 +1,123 @@
+// Package kmsv1 adapts AWS SDK for Go v1 KMS clients to the kmsauth Encrypter, Decrypter and DataKeyGenerator interfaces.
+//
+//	generator := kmsauth.NewTokenGenerator(key, to, from, userType, "us-east-1",
+//		kmsauth.WithEncrypter(kmsv1.New(kmsv1.Config{}.NewClient("us-east-1"))))
+package kmsv1
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+)
+
+// Config configures the SDK v1 KMS clients made by NewClient.
+type Config struct {
+	// Session is the AWS session clients are created from, for example one with
+	// a custom credentials provider. If nil, session.New() is used.
+	Session client.ConfigProvider
+	// AWSConfigs are merged into the configuration of each client, after its region.
+	// Use them to set an endpoint, credentials or retries for KMS.
+	AWSConfigs []*aws.Config
+	// UserAgent is appended to the User-Agent of KMS requests.
+	UserAgent string
+}
+
+// NewClient returns a new SDK v1 KMS client for region.
+func (c Config) NewClient(region string) *kms.KMS {
+	provider := c.Session
+	if provider == nil {
+		provider = session.New()
+	}
+	configs := append([]*aws.Config{{Region: aws.String(region)}}, c.AWSConfigs...)
+	client := kms.New(provider, configs...)
+	if c.UserAgent != "" {
+		client.Handlers.Build.PushBack(request.MakeAddToUserAgentFreeFormHandler(c.UserAgent))
+	}
+	return client
+}
+
+// KMS is a kmsauth.Encrypter, kmsauth.Decrypter and kmsauth.DataKeyGenerator that calls KMS
+// with an SDK v1 client. Contexts that can never be canceled, such as context.Background(),
+// call the client's Encrypt, Decrypt and GenerateDataKey methods, so that clients only
+// implementing those keep working; other contexts call their WithContext variants.
+type KMS struct {
+	Client kmsiface.KMSAPI
+}
+
+// New returns a KMS that calls client.
+func New(client kmsiface.KMSAPI) *KMS {
+	return &KMS{Client: client}
+}
+
+// Encrypt encrypts plaintext with the KMS key keyID under encryptionContext.
+func (k *KMS) Encrypt(ctx context.Context, keyID string, plaintext []byte, encryptionContext map[string]string) ([]byte, error) {
+	input := &kms.EncryptInput{
+		Plaintext:         plaintext,
+		EncryptionContext: aws.StringMap(encryptionContext),
+		GrantTokens:       []*string{},
+		KeyId:             aws.String(keyID),
+	}
+	var resp *kms.EncryptOutput
+	var err error
+	if ctx.Done() == nil {
+		resp, err = k.Client.Encrypt(input)
+	} else {
+		resp, err = k.Client.EncryptWithContext(ctx, input)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp.CiphertextBlob, nil
+}
+
+// Decrypt decrypts ciphertext under encryptionContext, and returns the plaintext
+// and the ARN of the key it was encrypted with. If keyID is not empty, KMS only
+// decrypts ciphertext encrypted with that key.
+func (k *KMS) Decrypt(ctx context.Context, keyID string, ciphertext []byte, encryptionContext map[string]string) ([]byte, string, error) {
+	input := &kms.DecryptInput{
+		CiphertextBlob:    ciphertext,
+		EncryptionContext: aws.StringMap(encryptionContext),
+		GrantTokens:       []*string{},
+	}
+	if keyID != "" {
+		input.KeyId = aws.String(keyID)
+	}
+	var resp *kms.DecryptOutput
+	var err error
+	if ctx.Done() == nil {
+		resp, err = k.Client.Decrypt(input)
+	} else {
+		resp, err = k.Client.DecryptWithContext(ctx, input)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return resp.Plaintext, aws.StringValue(resp.KeyId), nil
+}
+
+// GenerateDataKey generates a data key of size bytes under the KMS key keyID and
+// encryptionContext, and returns its plaintext and ciphertext.
+func (k *KMS) GenerateDataKey(ctx context.Context, keyID string, size int, encryptionContext map[string]string) ([]byte, []byte, error) {
+	input := &kms.GenerateDataKeyInput{
+		KeyId:             aws.String(keyID),
+		EncryptionContext: aws.StringMap(encryptionContext),
+		GrantTokens:       []*string{},
+		NumberOfBytes:     aws.Int64(int64(size)),
+	}
+	var resp *kms.GenerateDataKeyOutput
+	var err error
+	if ctx.Done() == nil {
+		resp, err = k.Client.GenerateDataKey(input)
+	} else {
+		resp, err = k.Client.GenerateDataKeyWithContext(ctx, input)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp.Plaintext, resp.CiphertextBlob, nil
+}
@@ -0,0 +1,183 @@
+package kmsv1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+)
+
+type mockKMSClient struct {
+	kmsiface.KMSAPI
+	encryptInput *kms.EncryptInput
+	decryptInput *kms.DecryptInput
+	dataKeyInput *kms.GenerateDataKeyInput
+	err          error
+	withContext  int
+}
+
+func (m *mockKMSClient) EncryptWithContext(ctx aws.Context, input *kms.EncryptInput, opts ...request.Option) (*kms.EncryptOutput, error) {
+	m.withContext++
+	return m.Encrypt(input)
+}
+
+func (m *mockKMSClient) Encrypt(input *kms.EncryptInput) (*kms.EncryptOutput, error) {
+	m.encryptInput = input
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &kms.EncryptOutput{CiphertextBlob: []byte("ciphertext")}, nil
+}
+
+func (m *mockKMSClient) DecryptWithContext(ctx aws.Context, input *kms.DecryptInput, opts ...request.Option) (*kms.DecryptOutput, error) {
+	m.withContext++
+	return m.Decrypt(input)
+}
+
+func (m *mockKMSClient) Decrypt(input *kms.DecryptInput) (*kms.DecryptOutput, error) {
+	m.decryptInput = input
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &kms.DecryptOutput{Plaintext: []byte("plaintext"), KeyId: aws.String("arn:aws:kms:us-east-1:123456789012:key/test")}, nil
+}
+
+func (m *mockKMSClient) GenerateDataKeyWithContext(ctx aws.Context, input *kms.GenerateDataKeyInput, opts ...request.Option) (*kms.GenerateDataKeyOutput, error) {
+	m.withContext++
+	return m.GenerateDataKey(input)
+}
+
+func (m *mockKMSClient) GenerateDataKey(input *kms.GenerateDataKeyInput) (*kms.GenerateDataKeyOutput, error) {
+	m.dataKeyInput = input
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &kms.GenerateDataKeyOutput{Plaintext: []byte("data key"), CiphertextBlob: []byte("encrypted data key")}, nil
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	client := &mockKMSClient{}
+	k := New(client)
+	encryptionContext := map[string]string{"from": "someone", "to": "confidant"}
+
+	ciphertext, err := k.Encrypt(context.Background(), "alias/authnz", []byte("plaintext"), encryptionContext)
+	if err != nil {
+		t.Fatalf("Could not encrypt: %s", err)
+	}
+	if string(ciphertext) != "ciphertext" {
+		t.Errorf("Expected ciphertext, got %q", ciphertext)
+	}
+	if aws.StringValue(client.encryptInput.KeyId) != "alias/authnz" || string(client.encryptInput.Plaintext) != "plaintext" {
+		t.Errorf("Unexpected encrypt input %+v", client.encryptInput)
+	}
+	if !reflect.DeepEqual(aws.StringValueMap(client.encryptInput.EncryptionContext), encryptionContext) {
+		t.Errorf("Expected encryption context %v, got %v", encryptionContext, client.encryptInput.EncryptionContext)
+	}
+
+	plaintext, keyARN, err := k.Decrypt(context.Background(), "alias/authnz", ciphertext, encryptionContext)
+	if err != nil {
+		t.Fatalf("Could not decrypt: %s", err)
+	}
+	if string(plaintext) != "plaintext" || keyARN != "arn:aws:kms:us-east-1:123456789012:key/test" {
+		t.Errorf("Unexpected plaintext %q and key %s", plaintext, keyARN)
+	}
+	if aws.StringValue(client.decryptInput.KeyId) != "alias/authnz" {
+		t.Errorf("Expected the key ID to be passed to KMS, got %+v", client.decryptInput)
+	}
+	if !reflect.DeepEqual(aws.StringValueMap(client.decryptInput.EncryptionContext), encryptionContext) {
+		t.Errorf("Expected encryption context %v, got %v", encryptionContext, client.decryptInput.EncryptionContext)
+	}
+
+	client.err = errors.New("AccessDeniedException")
+	if _, err := k.Encrypt(context.Background(), "alias/authnz", []byte("plaintext"), encryptionContext); err != client.err {
+		t.Errorf("Expected the KMS error, got %v", err)
+	}
+	if _, _, err := k.Decrypt(context.Background(), "alias/authnz", ciphertext, encryptionContext); err != client.err {
+		t.Errorf("Expected the KMS error, got %v", err)
+	}
+}
+
+func TestGenerateDataKey(t *testing.T) {
+	client := &mockKMSClient{}
+	k := New(client)
+	encryptionContext := map[string]string{"to": "service-name"}
+	plaintext, ciphertext, err := k.GenerateDataKey(context.Background(), "alias/blind", 32, encryptionContext)
+	if err != nil {
+		t.Fatalf("Could not generate a data key: %s", err)
+	}
+	if string(plaintext) != "data key" || string(ciphertext) != "encrypted data key" {
+		t.Errorf("Unexpected data key %q and ciphertext %q", plaintext, ciphertext)
+	}
+	if aws.StringValue(client.dataKeyInput.KeyId) != "alias/blind" || aws.Int64Value(client.dataKeyInput.NumberOfBytes) != 32 {
+		t.Errorf("Unexpected data key input %+v", client.dataKeyInput)
+	}
+	if !reflect.DeepEqual(aws.StringValueMap(client.dataKeyInput.EncryptionContext), encryptionContext) {
+		t.Errorf("Expected encryption context %v, got %v", encryptionContext, client.dataKeyInput.EncryptionContext)
+	}
+
+	client.err = errors.New("AccessDeniedException")
+	if _, _, err := k.GenerateDataKey(context.Background(), "alias/blind", 32, encryptionContext); err != client.err {
+		t.Errorf("Expected the KMS error, got %v", err)
+	}
+}
+
+func TestContext(t *testing.T) {
+	client := &mockKMSClient{}
+	k := New(client)
+	k.Encrypt(context.Background(), "alias/authnz", []byte("plaintext"), nil)
+	k.Decrypt(context.Background(), "", []byte("ciphertext"), nil)
+	k.GenerateDataKey(context.Background(), "alias/blind", 32, nil)
+	if client.withContext != 0 {
+		t.Errorf("Expected Encrypt, Decrypt and GenerateDataKey to be called for a background context")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	k.Encrypt(ctx, "alias/authnz", []byte("plaintext"), nil)
+	k.Decrypt(ctx, "", []byte("ciphertext"), nil)
+	k.GenerateDataKey(ctx, "alias/blind", 32, nil)
+	if client.withContext != 3 {
+		t.Errorf("Expected the WithContext methods to be called, got %d calls", client.withContext)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	var userAgent string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+		json.NewEncoder(w).Encode(map[string]interface{}{"CiphertextBlob": "dG9rZW4=", "KeyId": "key"})
+	}))
+	defer ts.Close()
+	config := Config{
+		Session: session.Must(session.NewSession(&aws.Config{
+			Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+		})),
+		AWSConfigs: []*aws.Config{{Endpoint: aws.String(ts.URL)}},
+		UserAgent:  "my-service/1.0",
+	}
+	client := config.NewClient("us-east-1")
+	if region := aws.StringValue(client.Config.Region); region != "us-east-1" {
+		t.Errorf("Expected a client for us-east-1, got %s", region)
+	}
+	ciphertext, err := New(client).Encrypt(context.Background(), "key", []byte("plaintext"), nil)
+	if err != nil {
+		t.Fatalf("Could not encrypt: %s", err)
+	}
+	if string(ciphertext) != "token" {
+		t.Errorf("Expected ciphertext token, got %q", ciphertext)
+	}
+	if !strings.HasSuffix(userAgent, "my-service/1.0") {
+		t.Errorf("Expected the user agent to end with my-service/1.0, got %q", userAgent)
+	}
+}
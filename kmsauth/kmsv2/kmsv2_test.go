@@ -0,0 +1,116 @@
+package kmsv2
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/stripe/go-confidant-client/kmsauth"
+)
+
+type mockClient struct {
+	encryptInput *kms.EncryptInput
+	decryptInput *kms.DecryptInput
+	dataKeyInput *kms.GenerateDataKeyInput
+	err          error
+}
+
+func (m *mockClient) Encrypt(ctx context.Context, params *kms.EncryptInput, optFns ...func(*kms.Options)) (*kms.EncryptOutput, error) {
+	m.encryptInput = params
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &kms.EncryptOutput{CiphertextBlob: []byte("ciphertext")}, nil
+}
+
+func (m *mockClient) Decrypt(ctx context.Context, params *kms.DecryptInput, optFns ...func(*kms.Options)) (*kms.DecryptOutput, error) {
+	m.decryptInput = params
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &kms.DecryptOutput{Plaintext: []byte("plaintext"), KeyId: aws.String("arn:aws:kms:us-east-1:123456789012:key/test")}, nil
+}
+
+func (m *mockClient) GenerateDataKey(ctx context.Context, params *kms.GenerateDataKeyInput, optFns ...func(*kms.Options)) (*kms.GenerateDataKeyOutput, error) {
+	m.dataKeyInput = params
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &kms.GenerateDataKeyOutput{Plaintext: []byte("data key"), CiphertextBlob: []byte("encrypted data key")}, nil
+}
+
+var (
+	_ Client                   = &kms.Client{}
+	_ kmsauth.Encrypter        = &KMS{}
+	_ kmsauth.Decrypter        = &KMS{}
+	_ kmsauth.DataKeyGenerator = &KMS{}
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	client := &mockClient{}
+	k := New(client)
+	encryptionContext := map[string]string{"from": "someone", "to": "confidant"}
+
+	ciphertext, err := k.Encrypt(context.Background(), "alias/authnz", []byte("plaintext"), encryptionContext)
+	if err != nil {
+		t.Fatalf("Could not encrypt: %s", err)
+	}
+	if string(ciphertext) != "ciphertext" {
+		t.Errorf("Expected ciphertext, got %q", ciphertext)
+	}
+	if aws.ToString(client.encryptInput.KeyId) != "alias/authnz" || string(client.encryptInput.Plaintext) != "plaintext" {
+		t.Errorf("Unexpected encrypt input %+v", client.encryptInput)
+	}
+	if !reflect.DeepEqual(client.encryptInput.EncryptionContext, encryptionContext) {
+		t.Errorf("Expected encryption context %v, got %v", encryptionContext, client.encryptInput.EncryptionContext)
+	}
+
+	plaintext, keyARN, err := k.Decrypt(context.Background(), "alias/authnz", ciphertext, encryptionContext)
+	if err != nil {
+		t.Fatalf("Could not decrypt: %s", err)
+	}
+	if string(plaintext) != "plaintext" || keyARN != "arn:aws:kms:us-east-1:123456789012:key/test" {
+		t.Errorf("Unexpected plaintext %q and key %s", plaintext, keyARN)
+	}
+	if aws.ToString(client.decryptInput.KeyId) != "alias/authnz" {
+		t.Errorf("Expected the key ID to be passed to KMS, got %+v", client.decryptInput)
+	}
+	if !reflect.DeepEqual(client.decryptInput.EncryptionContext, encryptionContext) {
+		t.Errorf("Expected encryption context %v, got %v", encryptionContext, client.decryptInput.EncryptionContext)
+	}
+
+	client.err = errors.New("AccessDeniedException")
+	if _, err := k.Encrypt(context.Background(), "alias/authnz", []byte("plaintext"), encryptionContext); err != client.err {
+		t.Errorf("Expected the KMS error, got %v", err)
+	}
+	if _, _, err := k.Decrypt(context.Background(), "alias/authnz", ciphertext, encryptionContext); err != client.err {
+		t.Errorf("Expected the KMS error, got %v", err)
+	}
+}
+
+func TestGenerateDataKey(t *testing.T) {
+	client := &mockClient{}
+	k := New(client)
+	encryptionContext := map[string]string{"to": "service-name"}
+	plaintext, ciphertext, err := k.GenerateDataKey(context.Background(), "alias/blind", 32, encryptionContext)
+	if err != nil {
+		t.Fatalf("Could not generate a data key: %s", err)
+	}
+	if string(plaintext) != "data key" || string(ciphertext) != "encrypted data key" {
+		t.Errorf("Unexpected data key %q and ciphertext %q", plaintext, ciphertext)
+	}
+	if aws.ToString(client.dataKeyInput.KeyId) != "alias/blind" || aws.ToInt32(client.dataKeyInput.NumberOfBytes) != 32 {
+		t.Errorf("Unexpected data key input %+v", client.dataKeyInput)
+	}
+	if !reflect.DeepEqual(client.dataKeyInput.EncryptionContext, encryptionContext) {
+		t.Errorf("Expected encryption context %v, got %v", encryptionContext, client.dataKeyInput.EncryptionContext)
+	}
+
+	client.err = errors.New("AccessDeniedException")
+	if _, _, err := k.GenerateDataKey(context.Background(), "alias/blind", 32, encryptionContext); err != client.err {
+		t.Errorf("Expected the KMS error, got %v", err)
+	}
+}
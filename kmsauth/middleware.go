@@ -0,0 +1,51 @@
+package kmsauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+type principalKey struct{}
+
+// Middleware returns a handler that authenticates requests with the validator
+// before passing them on to next.
+// Requests without X-Auth-From and X-Auth-Token headers get a 401 response,
+// and requests with a token that does not validate get a 403 response.
+// If the token could not be checked, such as when KMS is unavailable, the response is a 503,
+// or a 500 if the validator has no Decrypter or KeyIDs.
+// The authenticated Principal is available to next via PrincipalFromContext.
+func (v *TokenValidator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username := r.Header.Get("X-Auth-From")
+		token := r.Header.Get("X-Auth-Token")
+		if username == "" || token == "" {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		principal, err := v.ValidateTokenWithContext(r.Context(), username, token)
+		if err != nil {
+			status := http.StatusServiceUnavailable
+			switch {
+			case errors.Is(err, ErrInvalidToken):
+				status = http.StatusForbidden
+			case errors.Is(err, errNoKMSClient), errors.Is(err, errNoKeyIDs):
+				status = http.StatusInternalServerError
+			}
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), principal)))
+	})
+}
+
+// NewContext returns a copy of ctx carrying the principal.
+func NewContext(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal authenticated by Middleware, if any.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok && principal != nil
+}
@@ -0,0 +1,107 @@
+package kmsauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/stripe/go-confidant-client/kmsauth/kmstest"
+)
+
+func TestMiddleware(t *testing.T) {
+	now := time.Now().UTC()
+	client := newTestKMS()
+	username, token := newTestToken(t, client, 2, now)
+	validator := newTestValidator(client, now)
+
+	var principal *Principal
+	handler := validator.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, _ = PrincipalFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name     string
+		username string
+		token    string
+		status   int
+	}{
+		{"valid", username, token, http.StatusOK},
+		{"missing username", "", token, http.StatusUnauthorized},
+		{"missing token", username, "", http.StatusUnauthorized},
+		{"invalid token", "2/user/someone-else", token, http.StatusForbidden},
+	}
+	for _, test := range tests {
+		principal = nil
+		req := httptest.NewRequest("GET", "/v1/services", nil)
+		if test.username != "" {
+			req.Header.Set("X-Auth-From", test.username)
+		}
+		if test.token != "" {
+			req.Header.Set("X-Auth-Token", test.token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != test.status {
+			t.Errorf("%s: expected status %d, got %d", test.name, test.status, w.Code)
+		}
+		if test.status != http.StatusOK && principal != nil {
+			t.Errorf("%s: expected handler not to be called", test.name)
+		}
+	}
+}
+
+func TestMiddlewarePrincipal(t *testing.T) {
+	now := time.Now().UTC()
+	client := newTestKMS()
+	username, token := newTestToken(t, client, 3, now)
+	validator := newTestValidator(client, now)
+	var principal *Principal
+	var ok bool
+	handler := validator.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, ok = PrincipalFromContext(r.Context())
+	}))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Auth-From", username)
+	req.Header.Set("X-Auth-Token", token)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if !ok {
+		t.Fatalf("Expected a principal in the request context")
+	}
+	expected := Principal{Username: "terraform-provider-confidant", UserType: "user", TokenVersion: 3}
+	if *principal != expected {
+		t.Errorf("Expected principal %+v, got %+v", expected, *principal)
+	}
+	if _, ok := PrincipalFromContext(req.Context()); ok {
+		t.Errorf("Expected no principal in an unauthenticated context")
+	}
+}
+
+func TestMiddlewareUnavailable(t *testing.T) {
+	now := time.Now().UTC()
+	client := newTestKMS()
+	username, token := newTestToken(t, client, 2, now)
+	handler := func(validator *TokenValidator) http.Handler {
+		return validator.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t.Errorf("Expected handler not to be called")
+		}))
+	}
+	req := httptest.NewRequest("GET", "/v1/services", nil)
+	req.Header.Set("X-Auth-From", username)
+	req.Header.Set("X-Auth-Token", token)
+
+	client.SetError(kmstest.OperationDecrypt, awserr.New("ThrottlingException", "Rate exceeded", nil))
+	w := httptest.NewRecorder()
+	handler(newTestValidator(client, now)).ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected a 503 when KMS is unavailable, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler(&TokenValidator{To: "confidant-production"}).ServeHTTP(w, req)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected a 500 without a Decrypter, got %d", w.Code)
+	}
+}
@@ -0,0 +1,123 @@
+package kmsauth
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	"github.com/stripe/go-confidant-client/kmsauth/kmsv1"
+)
+
+// Option configures the KMS client of a TokenGenerator or TokenValidator
+// made by NewTokenGenerator or NewTokenValidator.
+type Option func(*options)
+
+type options struct {
+	encrypter Encrypter
+	decrypter Decrypter
+	kmsClient kmsiface.KMSAPI
+	newKMS    func(region string) KMS
+
+	failoverCooldown time.Duration
+	regionTimeout    time.Duration
+}
+
+// WithEncrypter makes a TokenGenerator encrypt tokens with encrypter, such as
+// an SDK v1 client adapted by kmsv1.New or an SDK v2 client adapted by kmsv2.New.
+func WithEncrypter(encrypter Encrypter) Option {
+	return func(o *options) {
+		o.encrypter = encrypter
+	}
+}
+
+// WithDecrypter makes a TokenValidator decrypt tokens with decrypter, such as
+// an SDK v1 client adapted by kmsv1.New or an SDK v2 client adapted by kmsv2.New.
+func WithDecrypter(decrypter Decrypter) Option {
+	return func(o *options) {
+		o.decrypter = decrypter
+	}
+}
+
+// WithKMS makes a TokenGenerator encrypt and a TokenValidator decrypt tokens with kms.
+func WithKMS(kms KMS) Option {
+	return func(o *options) {
+		o.encrypter = kms
+		o.decrypter = kms
+	}
+}
+
+// WithKMSClient sets the KMSClient of a TokenGenerator or TokenValidator to the
+// SDK v1 client, instead of one created from session.New().
+func WithKMSClient(client kmsiface.KMSAPI) Option {
+	return func(o *options) {
+		o.kmsClient = client
+	}
+}
+
+// WithKMSFactory creates the KMS client of a region with newKMS, for tokens
+// encrypted or decrypted in a region without a client given by another option,
+// instead of an SDK v1 client from session.New().
+// NewFailoverTokenGenerator uses it for every key without an Encrypter.
+//
+//	kmsauth.WithKMSFactory(func(region string) kmsauth.KMS {
+//		return kmsv1.New(kmsv1.Config{}.NewClient(region))
+//	})
+func WithKMSFactory(newKMS func(region string) KMS) Option {
+	return func(o *options) {
+		o.newKMS = newKMS
+	}
+}
+
+// WithFailoverCooldown sets how long NewFailoverTokenGenerator's FailoverEncrypter
+// skips a region after KMS fails there.
+func WithFailoverCooldown(cooldown time.Duration) Option {
+	return func(o *options) {
+		o.failoverCooldown = cooldown
+	}
+}
+
+// WithRegionTimeout limits each region's KMS call made by NewFailoverTokenGenerator's
+// FailoverEncrypter, so that a slow region fails over instead of using up the caller's deadline.
+// It is DefaultRegionTimeout if not set; a negative timeout only uses the caller's deadline.
+func WithRegionTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.regionTimeout = timeout
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// encrypterFor returns the Encrypter or SDK v1 KMSClient of a TokenGenerator for region:
+// the Encrypter given by WithEncrypter, the client given by WithKMSClient, the Encrypter
+// WithKMSFactory creates, or else a client from session.New().
+func (o *options) encrypterFor(region string) (Encrypter, kmsiface.KMSAPI) {
+	if o.encrypter != nil {
+		return o.encrypter, nil
+	}
+	if o.kmsClient != nil {
+		return nil, o.kmsClient
+	}
+	if o.newKMS != nil {
+		return o.newKMS(region), nil
+	}
+	return nil, kmsv1.Config{}.NewClient(region)
+}
+
+// decrypterFor is like encrypterFor, for the Decrypter given by WithDecrypter.
+func (o *options) decrypterFor(region string) (Decrypter, kmsiface.KMSAPI) {
+	if o.decrypter != nil {
+		return o.decrypter, nil
+	}
+	if o.kmsClient != nil {
+		return nil, o.kmsClient
+	}
+	if o.newKMS != nil {
+		return o.newKMS(region), nil
+	}
+	return nil, kmsv1.Config{}.NewClient(region)
+}
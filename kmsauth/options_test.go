@@ -0,0 +1,50 @@
+package kmsauth
+
+import (
+	"testing"
+)
+
+func TestWithKMS(t *testing.T) {
+	generator := NewTokenGenerator("key", "to", "from", "user", "us-east-1", WithKMS(fakeEncrypter{}))
+	if generator.Encrypter != (fakeEncrypter{}) {
+		t.Errorf("Expected the given KMS client, got %T", generator.Encrypter)
+	}
+	validator := NewTokenValidator("key", "to", "us-east-1", WithKMS(fakeEncrypter{}))
+	if validator.Decrypter != (fakeEncrypter{}) {
+		t.Errorf("Expected the given KMS client, got %T", validator.Decrypter)
+	}
+}
+
+func TestWithKMSClient(t *testing.T) {
+	client := &countingKMSClient{}
+	generator := NewTokenGenerator("key", "to", "from", "user", "us-east-1", WithKMSClient(client))
+	if generator.KMSClient != client || generator.Encrypter != nil {
+		t.Errorf("Expected the given KMS client, got %T", generator.KMSClient)
+	}
+	validator := NewTokenValidator("key", "to", "us-east-1", WithKMSClient(client))
+	if validator.KMSClient != client || validator.Decrypter != nil {
+		t.Errorf("Expected the given KMS client, got %T", validator.KMSClient)
+	}
+}
+
+func TestWithKMSFactory(t *testing.T) {
+	var regions []string
+	factory := WithKMSFactory(func(region string) KMS {
+		regions = append(regions, region)
+		return fakeEncrypter{}
+	})
+	generator := NewTokenGenerator("key", "to", "from", "user", "eu-west-1", factory)
+	validator := NewTokenValidator("key", "to", "us-west-2", factory)
+	if generator.Encrypter == nil || validator.Decrypter == nil {
+		t.Errorf("Expected KMS clients from the factory")
+	}
+	if len(regions) != 2 || regions[0] != "eu-west-1" || regions[1] != "us-west-2" {
+		t.Errorf("Expected clients for eu-west-1 and us-west-2, got %v", regions)
+	}
+
+	regions = nil
+	NewTokenGenerator("key", "to", "from", "user", "eu-west-1", factory, WithEncrypter(&regionEncrypter{}))
+	if len(regions) != 0 {
+		t.Errorf("Expected WithEncrypter to be used instead of the factory, got clients for %v", regions)
+	}
+}
@@ -0,0 +1,77 @@
+package kmsauth
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// Transport is an http.RoundTripper that authenticates requests to kmsauth protected
+// services, such as Confidant, by adding X-Auth-From and X-Auth-Token headers from
+// TokenGenerator. If the service responds with 401 Unauthorized, the token is invalidated
+// and the request is retried once with a new token. Requests with a body are only retried
+// if their GetBody is set, as it is by http.NewRequest for common body types. If the new
+// token can't be generated, the 401 response is returned.
+//
+//	client := &http.Client{Transport: &kmsauth.Transport{TokenGenerator: &generator}}
+type Transport struct {
+	TokenGenerator *TokenGenerator
+	// Base makes the requests. If nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+}
+
+// RoundTrip makes an authenticated request, retrying it once with a new token on 401.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.TokenGenerator.GetTokenWithContext(req.Context())
+	if err != nil {
+		closeBody(req)
+		return nil, err
+	}
+	resp, err := t.base().RoundTrip(t.authenticate(req, token))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return resp, nil
+	}
+
+	t.TokenGenerator.InvalidateToken(token)
+	token, err = t.TokenGenerator.GetTokenWithContext(req.Context())
+	if err != nil {
+		return resp, nil
+	}
+	retry := t.authenticate(req, token)
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+		retry.Body = body
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	return t.base().RoundTrip(retry)
+}
+
+// authenticate returns a copy of req with the auth headers for token,
+// since a RoundTripper must not change its request.
+func (t *Transport) authenticate(req *http.Request, token string) *http.Request {
+	authenticated := req.Clone(req.Context())
+	authenticated.Header.Set("X-Auth-From", t.TokenGenerator.GetUsername())
+	authenticated.Header.Set("X-Auth-Token", token)
+	return authenticated
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+// closeBody closes the request body, which a RoundTripper must do even when it fails.
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
@@ -0,0 +1,161 @@
+package kmsauth
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stripe/go-confidant-client/kmsauth/kmstest"
+	"github.com/stripe/go-confidant-client/kmsauth/kmsv1"
+)
+
+// rejectingServer responds 401 to requests with a rejected token, and otherwise
+// echoes the request body. It records the tokens of requests.
+type rejectingServer struct {
+	mu       sync.Mutex
+	rejected map[string]bool
+	all      bool
+	tokens   []string
+}
+
+func (s *rejectingServer) rejectAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.all = true
+}
+
+func (s *rejectingServer) reject(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rejected[token] = true
+}
+
+func (s *rejectingServer) handler(validator *TokenValidator) http.Handler {
+	return validator.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("X-Auth-Token")
+		s.mu.Lock()
+		s.tokens = append(s.tokens, token)
+		rejected := s.all || s.rejected[token]
+		s.mu.Unlock()
+		if rejected {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write(body)
+	}))
+}
+
+func newTransportTest(t *testing.T) (*rejectingServer, *httptest.Server, *TokenGenerator, *kmstest.KMS) {
+	fake := newTestKMS()
+	now := time.Now().UTC()
+	generator := NewTokenGenerator("alias/authnz", "confidant-production", "someone", "user", "us-east-1", WithKMS(kmsv1.New(fake)))
+	server := &rejectingServer{rejected: make(map[string]bool)}
+	ts := httptest.NewServer(server.handler(newTestValidator(fake, now)))
+	return server, ts, &generator, fake
+}
+
+func TestTransport(t *testing.T) {
+	server, ts, generator, fake := newTransportTest(t)
+	defer ts.Close()
+	client := &http.Client{Transport: &Transport{TokenGenerator: generator}}
+
+	req, _ := http.NewRequest("POST", ts.URL, strings.NewReader("body"))
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Could not make request: %s", err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "body" {
+		t.Fatalf("Expected the body to be echoed, got %d %q", resp.StatusCode, body)
+	}
+	if req.Header.Get("X-Auth-Token") != "" {
+		t.Errorf("Expected the request not to be changed")
+	}
+
+	// A rejected token is replaced, and the request is retried with its body.
+	server.reject(server.tokens[0])
+	req, _ = http.NewRequest("POST", ts.URL, strings.NewReader("retried"))
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("Could not make request: %s", err)
+	}
+	body, _ = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "retried" {
+		t.Errorf("Expected the request to be retried, got %d %q", resp.StatusCode, body)
+	}
+	if len(server.tokens) != 3 || server.tokens[2] == server.tokens[0] {
+		t.Errorf("Expected the retry to use a new token, got %v", server.tokens)
+	}
+	if calls := fake.Calls(kmstest.OperationEncrypt); calls != 2 {
+		t.Errorf("Expected 2 tokens to be generated, got %d", calls)
+	}
+
+	// A request whose new token is rejected too is only retried once.
+	server.rejectAll()
+	resp, err = client.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("Could not make request: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized || len(server.tokens) != 5 {
+		t.Errorf("Expected a 401 after one retry, got %d after %d requests", resp.StatusCode, len(server.tokens))
+	}
+}
+
+func TestTransportNoRetry(t *testing.T) {
+	server, ts, generator, fake := newTransportTest(t)
+	defer ts.Close()
+	client := &http.Client{Transport: &Transport{TokenGenerator: generator}}
+	token, _ := generator.GetToken()
+	server.reject(token)
+
+	// Bodies that can't be read again aren't retried.
+	req, _ := http.NewRequest("POST", ts.URL, ioutil.NopCloser(bytes.NewReader([]byte("body"))))
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Could not make request: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized || len(server.tokens) != 1 {
+		t.Errorf("Expected a 401 without a retry, got %d after %d requests", resp.StatusCode, len(server.tokens))
+	}
+
+	// Without a new token, the 401 is returned.
+	kmsErr := errors.New("AccessDeniedException")
+	fake.SetError(kmstest.OperationEncrypt, kmsErr)
+	resp, err = client.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("Could not make request: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected a 401, got %d", resp.StatusCode)
+	}
+	if _, err := client.Get(ts.URL); !errors.Is(err, kmsErr) {
+		t.Errorf("Expected the KMS error, got %v", err)
+	}
+}
+
+func TestInvalidateToken(t *testing.T) {
+	generator := NewTokenGenerator("key", "confidant", "username", "user", "us-east-1")
+	client := &countingKMSClient{}
+	generator.Encrypter = kmsv1.New(client)
+	first, _ := generator.GetToken()
+	generator.InvalidateToken("another token")
+	if second, _ := generator.GetToken(); second != first || client.Calls != 1 {
+		t.Errorf("Expected the token to be kept after invalidating another, got %d calls", client.Calls)
+	}
+	generator.InvalidateToken(first)
+	if _, err := generator.GetToken(); err != nil || client.Calls != 2 {
+		t.Errorf("Expected a new token after invalidating it, got %d calls and %v", client.Calls, err)
+	}
+}
@@ -0,0 +1,351 @@
+package kmsauth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+)
+
+// DefaultValidatorCacheSize is the number of validated tokens a TokenValidator remembers.
+const DefaultValidatorCacheSize = 4096
+
+// ErrInvalidToken is returned (wrapped) by ValidateToken for every token that is rejected.
+// Errors that do not wrap it, such as KMS being unavailable, say nothing about the token.
+var ErrInvalidToken = errors.New("kmsauth: invalid token")
+
+var errNoKeyIDs = errors.New("No KeyIDs are set")
+
+// invalidCiphertextCodes are the KMS error codes for ciphertext that cannot be decrypted
+// under the encryption context, meaning the token itself is invalid.
+var invalidCiphertextCodes = []string{
+	"InvalidCiphertextException",
+	"IncorrectKeyException",
+}
+
+// Principal is the caller a token was generated for.
+type Principal struct {
+	// Username is the "from" of the token.
+	Username     string
+	UserType     string
+	TokenVersion int
+}
+
+// TokenValidator authenticates kmsauth tokens, the way Confidant does.
+type TokenValidator struct {
+	// To is the name of this service; tokens must be generated for it.
+	To string
+	// KMSClient is the AWS SDK v1 KMS client tokens are decrypted with when Decrypter is nil.
+	KMSClient kmsiface.KMSAPI
+	// Decrypter decrypts tokens with KMS, such as an SDK v1 client adapted by kmsv1.New
+	// or an SDK v2 client adapted by kmsv2.New. If set, it is used instead of KMSClient.
+	Decrypter Decrypter
+	// KeyIDs are the KMS keys tokens may be encrypted with, as key IDs, key ARNs, alias names
+	// or alias ARNs. Tokens encrypted with any other key are rejected, so at least one is required.
+	// Each is passed to KMS, which refuses to decrypt tokens encrypted with another key.
+	KeyIDs []string
+	// UserTypes, if set, are the user types that are accepted.
+	UserTypes []string
+	// MinTokenVersion and MaxTokenVersion bound the accepted token versions.
+	// Zero means the package's MinTokenVersion and MaxTokenVersion.
+	MinTokenVersion int
+	MaxTokenVersion int
+	// MaxTokenLifetime is the longest window between not_before and not_after
+	// that is accepted. Zero means DefaultMaxTokenLifetime.
+	MaxTokenLifetime time.Duration
+	// CacheSize is the number of validated tokens to remember, so that
+	// repeated requests with the same token do not call KMS.
+	// Zero means DefaultValidatorCacheSize; a negative value disables caching.
+	CacheSize int
+
+	mu    sync.Mutex
+	cache map[string]validatedToken
+	now   func() time.Time
+}
+
+type validatedToken struct {
+	principal Principal
+	notAfter  time.Time
+}
+
+// NewTokenValidator returns a TokenValidator for tokens to "to" encrypted with the KMS key keyID,
+// decrypted by KMS in region. More keys, such as those of a failover generator, can be added to KeyIDs.
+// Its Decrypter is the one given by WithDecrypter, or the one WithKMSFactory creates for region.
+// Without either, its KMSClient is the one given by WithKMSClient, or an SDK v1 client
+// for region created from session.New().
+func NewTokenValidator(keyID string, to string, region string, opts ...Option) TokenValidator {
+	o := newOptions(opts)
+	decrypter, kmsClient := o.decrypterFor(region)
+	return TokenValidator{
+		To:               to,
+		KeyIDs:           []string{keyID},
+		KMSClient:        kmsClient,
+		Decrypter:        decrypter,
+		MinTokenVersion:  MinTokenVersion,
+		MaxTokenVersion:  MaxTokenVersion,
+		MaxTokenLifetime: DefaultMaxTokenLifetime,
+		CacheSize:        DefaultValidatorCacheSize,
+	}
+}
+
+// ParseUsername splits a username sent with a token into its version, user type and "from".
+// Version 1 usernames are the bare "from", and always have the user type "service".
+func ParseUsername(username string) (version int, userType string, from string, err error) {
+	parts := strings.Split(username, "/")
+	switch len(parts) {
+	case 1:
+		version, userType, from = 1, "service", parts[0]
+	case 3:
+		version, err = strconv.Atoi(parts[0])
+		if err != nil || version < 2 {
+			return 0, "", "", fmt.Errorf("%w: unsupported version in username %q", ErrInvalidToken, username)
+		}
+		userType, from = parts[1], parts[2]
+	default:
+		return 0, "", "", fmt.Errorf("%w: unsupported username format %q", ErrInvalidToken, username)
+	}
+	if from == "" || userType == "" {
+		return 0, "", "", fmt.Errorf("%w: unsupported username format %q", ErrInvalidToken, username)
+	}
+	return version, userType, from, nil
+}
+
+// ValidateToken checks a username (X-Auth-From) and base64 encoded token (X-Auth-Token)
+// and returns who the token was generated for.
+// Errors for rejected tokens wrap ErrInvalidToken; other errors, such as KMS throttling
+// or network errors, are returned as they are.
+// It is safe to call ValidateToken from multiple goroutines.
+func (v *TokenValidator) ValidateToken(username, token string) (*Principal, error) {
+	return v.ValidateTokenWithContext(context.Background(), username, token)
+}
+
+// ValidateTokenWithContext is like ValidateToken, but takes a context for the KMS call.
+func (v *TokenValidator) ValidateTokenWithContext(ctx context.Context, username, token string) (*Principal, error) {
+	if len(v.KeyIDs) == 0 {
+		return nil, errNoKeyIDs
+	}
+	version, userType, from, err := ParseUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	if err := v.checkPrincipal(version, userType); err != nil {
+		return nil, err
+	}
+	now := v.clock().UTC()
+	key := strings.Join(v.KeyIDs, ",") + "\x00" + username + "\x00" + token
+	if principal, ok := v.cached(key, now); ok {
+		return principal, nil
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: token is not base64 encoded", ErrInvalidToken)
+	}
+	encryptionContext := map[string]string{
+		"from": from,
+		"to":   v.To,
+	}
+	if version != 1 {
+		encryptionContext["user_type"] = userType
+	}
+	decrypter, err := v.decrypter()
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := v.decrypt(ctx, decrypter, ciphertext, encryptionContext)
+	if err != nil {
+		return nil, err
+	}
+	notAfter, err := v.checkPayload(plaintext, now)
+	if err != nil {
+		return nil, err
+	}
+
+	principal := Principal{
+		Username:     from,
+		UserType:     userType,
+		TokenVersion: version,
+	}
+	v.store(key, validatedToken{principal: principal, notAfter: notAfter}, now)
+	return &principal, nil
+}
+
+// decrypt decrypts ciphertext with the first of KeyIDs it was encrypted with.
+func (v *TokenValidator) decrypt(ctx context.Context, decrypter Decrypter, ciphertext []byte, encryptionContext map[string]string) ([]byte, error) {
+	var err error
+	for _, keyID := range v.KeyIDs {
+		var plaintext []byte
+		var keyARN string
+		plaintext, keyARN, err = decrypter.Decrypt(ctx, keyID, ciphertext, encryptionContext)
+		if isIncorrectKey(err) {
+			continue
+		}
+		if err != nil {
+			break
+		}
+		// KMS checks keyID, but a Decrypter that ignores it must not let other keys through.
+		if !matchesKey(keyID, keyARN) {
+			return nil, fmt.Errorf("%w: token was encrypted with unauthorized key %s", ErrInvalidToken, keyARN)
+		}
+		return plaintext, nil
+	}
+	if isInvalidCiphertext(err) {
+		return nil, fmt.Errorf("%w: could not decrypt token: %s", ErrInvalidToken, err)
+	}
+	return nil, fmt.Errorf("Could not decrypt token: %w", err)
+}
+
+// matchesKey reports whether keyARN, returned by KMS, is the key keyID refers to.
+// Aliases can't be resolved without KMS, which has already checked them.
+func matchesKey(keyID, keyARN string) bool {
+	switch {
+	case strings.HasPrefix(keyID, "alias/") || strings.Contains(keyID, ":alias/"):
+		return true
+	case strings.HasPrefix(keyID, "arn:"):
+		return keyARN == keyID
+	default:
+		return strings.HasSuffix(keyARN, ":key/"+keyID)
+	}
+}
+
+// checkPrincipal checks the token version and user type are accepted.
+func (v *TokenValidator) checkPrincipal(version int, userType string) error {
+	minVersion := v.MinTokenVersion
+	if minVersion == 0 {
+		minVersion = MinTokenVersion
+	}
+	maxVersion := v.MaxTokenVersion
+	if maxVersion == 0 {
+		maxVersion = MaxTokenVersion
+	}
+	if version < minVersion || version > maxVersion {
+		return fmt.Errorf("%w: unsupported token version %d", ErrInvalidToken, version)
+	}
+	if len(v.UserTypes) != 0 && !containsString(v.UserTypes, userType) {
+		return fmt.Errorf("%w: unsupported user type %s", ErrInvalidToken, userType)
+	}
+	return nil
+}
+
+// checkPayload checks the decrypted token is valid now.
+// It returns the time at which the token expires.
+func (v *TokenValidator) checkPayload(plaintext []byte, now time.Time) (time.Time, error) {
+	var payload Payload
+	if err := json.Unmarshal(plaintext, &payload); err != nil {
+		return time.Time{}, fmt.Errorf("%w: could not parse token payload", ErrInvalidToken)
+	}
+	notBefore, err := time.Parse(TimeFormat, payload.NotBefore)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: could not parse not_before", ErrInvalidToken)
+	}
+	notAfter, err := time.Parse(TimeFormat, payload.NotAfter)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: could not parse not_after", ErrInvalidToken)
+	}
+	maxLifetime := v.MaxTokenLifetime
+	if maxLifetime == 0 {
+		maxLifetime = DefaultMaxTokenLifetime
+	}
+	if notAfter.Sub(notBefore) > maxLifetime {
+		return time.Time{}, fmt.Errorf("%w: token lifetime exceeds the maximum of %s", ErrInvalidToken, maxLifetime)
+	}
+	if now.Before(notBefore) {
+		return time.Time{}, fmt.Errorf("%w: token is not valid until %s", ErrInvalidToken, notBefore)
+	}
+	if now.After(notAfter) {
+		return time.Time{}, fmt.Errorf("%w: token expired at %s", ErrInvalidToken, notAfter)
+	}
+	return notAfter, nil
+}
+
+func (v *TokenValidator) cached(key string, now time.Time) (*Principal, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	entry, ok := v.cache[key]
+	if !ok {
+		return nil, false
+	}
+	if now.After(entry.notAfter) {
+		delete(v.cache, key)
+		return nil, false
+	}
+	principal := entry.principal
+	return &principal, true
+}
+
+func (v *TokenValidator) store(key string, entry validatedToken, now time.Time) {
+	size := v.CacheSize
+	if size == 0 {
+		size = DefaultValidatorCacheSize
+	}
+	if size < 0 {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.cache == nil {
+		v.cache = make(map[string]validatedToken)
+	}
+	if len(v.cache) >= size {
+		for k, e := range v.cache {
+			if now.After(e.notAfter) {
+				delete(v.cache, k)
+			}
+		}
+	}
+	for k := range v.cache {
+		if len(v.cache) < size {
+			break
+		}
+		delete(v.cache, k)
+	}
+	v.cache[key] = entry
+}
+
+func (v *TokenValidator) clock() time.Time {
+	if v.now != nil {
+		return v.now()
+	}
+	return time.Now()
+}
+
+// isInvalidCiphertext reports whether a KMS error is for invalid ciphertext.
+func isInvalidCiphertext(err error) bool {
+	return containsString(invalidCiphertextCodes, errorCode(err))
+}
+
+// isIncorrectKey reports whether a KMS error is for ciphertext encrypted with another key
+// than the one given to Decrypt.
+func isIncorrectKey(err error) bool {
+	return errorCode(err) == "IncorrectKeyException"
+}
+
+// errorCode returns the code of a KMS error. Errors of both AWS SDK v1 (Code) and
+// v2 (ErrorCode) carry their code, and are checked without importing either.
+func errorCode(err error) string {
+	var v1Err interface{ Code() string }
+	if errors.As(err, &v1Err) {
+		return v1Err.Code()
+	}
+	var v2Err interface{ ErrorCode() string }
+	if errors.As(err, &v2Err) {
+		return v2Err.ErrorCode()
+	}
+	return ""
+}
+
+func containsString(slice []string, element string) bool {
+	for _, s := range slice {
+		if s == element {
+			return true
+		}
+	}
+	return false
+}
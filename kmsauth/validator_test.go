@@ -0,0 +1,218 @@
+package kmsauth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	"github.com/stripe/go-confidant-client/kmsauth/kmstest"
+	"github.com/stripe/go-confidant-client/kmsauth/kmsv1"
+)
+
+// newTestKMS returns an in-memory KMS with the key alias/authnz, which newTestToken encrypts with.
+func newTestKMS() *kmstest.KMS {
+	fake := kmstest.New()
+	fake.AddKey("alias/authnz")
+	return fake
+}
+
+func newTestValidator(client kmsiface.KMSAPI, now time.Time) *TokenValidator {
+	validator := NewTokenValidator("alias/authnz", "confidant-production", "us-east-1")
+	validator.KMSClient = client
+	validator.now = func() time.Time { return now }
+	return &validator
+}
+
+func newTestToken(t *testing.T, client kmsiface.KMSAPI, version int, now time.Time) (string, string) {
+	generator := NewTokenGenerator("alias/authnz", "confidant-production", "terraform-provider-confidant", "user", "us-east-1")
+	generator.KMSClient = client
+	generator.TokenVersion = version
+	generator.now = func() time.Time { return now }
+	token, err := generator.GetToken()
+	if err != nil {
+		t.Fatalf("Could not get token: %s", err)
+	}
+	return generator.GetUsername(), token
+}
+
+func TestParseUsername(t *testing.T) {
+	tests := []struct {
+		username string
+		version  int
+		userType string
+		from     string
+	}{
+		{"terraform-provider-confidant", 1, "service", "terraform-provider-confidant"},
+		{"2/user/terraform-provider-confidant", 2, "user", "terraform-provider-confidant"},
+		{"3/service/terraform-provider-confidant", 3, "service", "terraform-provider-confidant"},
+	}
+	for _, test := range tests {
+		version, userType, from, err := ParseUsername(test.username)
+		if err != nil {
+			t.Errorf("Could not parse %s: %s", test.username, err)
+		}
+		if version != test.version || userType != test.userType || from != test.from {
+			t.Errorf(
+				"Parsed %s incorrectly, got (%d, %s, %s), want (%d, %s, %s)",
+				test.username, version, userType, from, test.version, test.userType, test.from,
+			)
+		}
+	}
+	for _, username := range []string{"", "2/user", "x/user/foo", "1/user/foo", "2//foo"} {
+		if _, _, _, err := ParseUsername(username); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Expected ErrInvalidToken for username %q, got %v", username, err)
+		}
+	}
+}
+
+func TestValidateToken(t *testing.T) {
+	now := time.Date(2018, 7, 3, 17, 3, 1, 0, time.UTC)
+	for _, version := range []int{1, 2, 3} {
+		client := newTestKMS()
+		username, token := newTestToken(t, client, version, now)
+		validator := newTestValidator(client, now.Add(time.Minute))
+		principal, err := validator.ValidateToken(username, token)
+		if err != nil {
+			t.Fatalf("Version %d: could not validate token: %s", version, err)
+		}
+		expectedUserType := "user"
+		if version == 1 {
+			expectedUserType = "service"
+		}
+		expected := Principal{Username: "terraform-provider-confidant", UserType: expectedUserType, TokenVersion: version}
+		if *principal != expected {
+			t.Errorf("Version %d: expected principal %+v, got %+v", version, expected, *principal)
+		}
+	}
+}
+
+func TestValidateTokenCache(t *testing.T) {
+	now := time.Date(2018, 7, 3, 17, 3, 1, 0, time.UTC)
+	client := newTestKMS()
+	username, token := newTestToken(t, client, 2, now)
+	validator := newTestValidator(client, now)
+	for i := 0; i < 3; i++ {
+		if _, err := validator.ValidateToken(username, token); err != nil {
+			t.Fatalf("Could not validate token: %s", err)
+		}
+	}
+	if calls := client.Calls(kmstest.OperationDecrypt); calls != 1 {
+		t.Errorf("Expected 1 call to KMS, got %d", calls)
+	}
+	// Cached tokens still expire.
+	validator.now = func() time.Time { return now.Add(61 * time.Minute) }
+	if _, err := validator.ValidateToken(username, token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected an expired token to be rejected, got %v", err)
+	}
+}
+
+func TestValidateTokenRejected(t *testing.T) {
+	now := time.Date(2018, 7, 3, 17, 3, 1, 0, time.UTC)
+	client := newTestKMS()
+	username, token := newTestToken(t, client, 2, now)
+
+	tests := []struct {
+		name     string
+		username string
+		token    string
+		setup    func(*TokenValidator)
+	}{
+		{"expired", username, token, func(v *TokenValidator) {
+			v.now = func() time.Time { return now.Add(61 * time.Minute) }
+		}},
+		{"not yet valid", username, token, func(v *TokenValidator) {
+			v.now = func() time.Time { return now.Add(-time.Minute) }
+		}},
+		{"lifetime too long", username, token, func(v *TokenValidator) {
+			v.MaxTokenLifetime = 30 * time.Minute
+		}},
+		{"wrong service", username, token, func(v *TokenValidator) {
+			v.To = "another-service"
+		}},
+		{"wrong user", "2/user/someone-else", token, func(v *TokenValidator) {}},
+		{"unauthorized key", username, token, func(v *TokenValidator) {
+			v.KeyIDs = []string{client.AddKey()}
+		}},
+		{"unsupported version", username, token, func(v *TokenValidator) {
+			v.MinTokenVersion = 3
+		}},
+		{"unsupported user type", username, token, func(v *TokenValidator) {
+			v.UserTypes = []string{"service"}
+		}},
+		{"not base64", username, "%%%", func(v *TokenValidator) {}},
+	}
+	for _, test := range tests {
+		validator := newTestValidator(client, now)
+		test.setup(validator)
+		if _, err := validator.ValidateToken(test.username, test.token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: expected ErrInvalidToken, got %v", test.name, err)
+		}
+	}
+}
+
+// keyIgnoringDecrypter decrypts without passing the key ID to KMS, so that ciphertext
+// encrypted with any key is decrypted.
+type keyIgnoringDecrypter struct {
+	Decrypter
+}
+
+func (d keyIgnoringDecrypter) Decrypt(ctx context.Context, keyID string, ciphertext []byte, encryptionContext map[string]string) ([]byte, string, error) {
+	return d.Decrypter.Decrypt(ctx, "", ciphertext, encryptionContext)
+}
+
+func TestValidateTokenWrongKey(t *testing.T) {
+	now := time.Date(2018, 7, 3, 17, 3, 1, 0, time.UTC)
+	client := newTestKMS()
+	client.AddKey("alias/attacker")
+	generator := NewTokenGenerator("alias/attacker", "confidant-production", "terraform-provider-confidant", "user", "us-east-1")
+	generator.KMSClient = client
+	generator.now = func() time.Time { return now }
+	token, err := generator.GetToken()
+	if err != nil {
+		t.Fatalf("Could not get token: %s", err)
+	}
+
+	validator := newTestValidator(client, now)
+	if _, err := validator.ValidateToken(generator.GetUsername(), token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected a token encrypted with another key to be rejected, got %v", err)
+	}
+	validator.KeyIDs = append(validator.KeyIDs, "alias/attacker")
+	if _, err := validator.ValidateToken(generator.GetUsername(), token); err != nil {
+		t.Errorf("Expected a token encrypted with the second key to be accepted, got %v", err)
+	}
+
+	// Key IDs and ARNs are also checked against the key KMS decrypted the token with.
+	validator = newTestValidator(client, now)
+	validator.Decrypter = keyIgnoringDecrypter{kmsv1.New(client)}
+	for _, keyID := range []string{client.AddKey(), "arn:aws:kms:us-east-1:123456789012:key/other"} {
+		validator.KeyIDs = []string{keyID}
+		if _, err := validator.ValidateToken(generator.GetUsername(), token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Expected a token encrypted with another key than %s to be rejected, got %v", keyID, err)
+		}
+	}
+
+	validator.KeyIDs = nil
+	if _, err := validator.ValidateToken(generator.GetUsername(), token); !errors.Is(err, errNoKeyIDs) {
+		t.Errorf("Expected an error without KeyIDs, got %v", err)
+	}
+}
+
+func TestValidateTokenKMSError(t *testing.T) {
+	now := time.Date(2018, 7, 3, 17, 3, 1, 0, time.UTC)
+	client := newTestKMS()
+	username, token := newTestToken(t, client, 2, now)
+	throttled := awserr.New("ThrottlingException", "Rate exceeded", nil)
+	client.SetError(kmstest.OperationDecrypt, throttled)
+
+	validator := newTestValidator(client, now)
+	_, err := validator.ValidateToken(username, token)
+	if err == nil || errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected a KMS error that does not wrap ErrInvalidToken, got %v", err)
+	}
+	if !errors.Is(err, throttled) {
+		t.Errorf("Expected the KMS error to be wrapped, got %v", err)
+	}
+}